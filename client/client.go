@@ -1,6 +1,13 @@
 package main
 
 import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gopherjs/gopherjs/js"
 	"github.com/gopherjs/vecty"
 	"github.com/iafan/goplayspace/client/component/app"
 	"github.com/iafan/goplayspace/client/component/drawboard"
@@ -10,15 +17,54 @@ import (
 func main() {
 	vecty.SetTitle("Gophers")
 
-	actions := draw.NewHTTPActorsList("http://localhost:8080")
+	a := &app.Application{}
+
+	// `?replay=<session file URL>[&replayspeed=<multiplier>]`
+	// replays a recorded session instead of polling the server
+	search := js.Global.Get("location").Get("search").String()
+	query, _ := url.ParseQuery(strings.TrimPrefix(search, "?"))
+
+	var actions draw.ActorsList
+	if replayURL := query.Get("replay"); replayURL != "" {
+		speed, err := strconv.ParseFloat(query.Get("replayspeed"), 64)
+		if err != nil {
+			speed = 1
+		}
+
+		actions, err = loadReplay(replayURL, speed, a.LogError)
+		if err != nil {
+			a.LogError("", err)
+		}
+	} else {
+		actions = draw.NewHTTPActorsList("http://localhost:8080", a.LogError)
+	}
+
+	a.DrawBoard = drawboard.New(actions)
 
-	a := &app.Application{
-		DrawBoard: drawboard.New(actions),
+	// `?edge=none|clamp|wrap|bounce` sets what happens
+	// when gophers reach the edge of the board
+	switch edge := query.Get("edge"); edge {
+	case draw.EdgeNone, draw.EdgeClamp, draw.EdgeWrap, draw.EdgeBounce:
+		a.DrawBoard.SetEdge(edge)
 	}
 
 	vecty.RenderBody(a)
 }
 
+func loadReplay(url string, speed float64, onError func(id string, err error)) (draw.ActorsList, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("error loading session %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error loading session %s: %s", url, resp.Status)
+	}
+
+	return draw.NewReplayActorsList(resp.Body, speed, onError)
+}
+
 const houseStr = `draw mode
 
 // draw the roof
@@ -1,14 +1,26 @@
 package app
 
 import (
+	"bytes"
 	"fmt"
 	"regexp"
+	"strconv"
 	"time"
 
 	"github.com/gopherjs/vecty"
 	"github.com/gopherjs/vecty/elem"
+	"github.com/gopherjs/vecty/event"
 	"github.com/iafan/goplayspace/client/component/drawboard"
+	"github.com/iafan/goplayspace/client/component/editor"
+	"github.com/iafan/goplayspace/client/component/inspector"
+	"github.com/iafan/goplayspace/client/component/layers"
+	"github.com/iafan/goplayspace/client/component/log"
+	"github.com/iafan/goplayspace/client/component/settings"
+	"github.com/iafan/goplayspace/client/draw"
 	"github.com/iafan/goplayspace/client/hash"
+	"github.com/iafan/goplayspace/client/js/download"
+	"github.com/iafan/goplayspace/client/prefs"
+	"github.com/iafan/goplayspace/client/ranges"
 	"github.com/iafan/goplayspace/client/util"
 	"honnef.co/go/js/xhr"
 )
@@ -22,17 +34,33 @@ type Application struct {
 
 	isLoading     bool
 	isDrawingMode bool
+	isMounted     bool
 	needRender    bool
+	hashPending   bool // viewer state changes wait to be put into the hash
+	showSettings  bool
+
+	prefs prefs.Prefs
 
 	// Log properties
-	hasRun bool
+	hasRun      bool
+	logEntries  []log.Entry
+	artistNames map[string]string
+	artistOrder []string
 
 	// Draw mode properties
 	DrawBoard *drawboard.DrawBoard
+	session   bytes.Buffer // recorded session file
+	inspected string       // ID of the artist shown in the inspector
+
+	// Listing properties
+	programs      map[string][]string // artist ID => source lines received so far
+	current       map[string]int      // artist ID => line being executed
+	firstArtistID string
 }
 
 func (a *Application) rerenderIfNeeded() {
-	if !a.needRender {
+	// errors can be logged before the application is rendered
+	if !a.needRender || !a.isMounted {
 		return
 	}
 	a.needRender = false
@@ -50,6 +78,15 @@ var fmtErrorLineExtractorR = regexp.MustCompile(`(?m)^(\d+):(\d+):\s`)
 
 var domMonitorInterval = 5 * time.Millisecond
 
+// hashUpdateDelay groups bursts of viewer state changes (e.g. zooming
+// with the mouse wheel) into a single address bar update
+const hashUpdateDelay = 300 * time.Millisecond
+
+const title = "Gophers"
+
+// maxLogEntries limits the number of entries kept in the activity log
+const maxLogEntries = 1000
+
 func (a *Application) doRun() {
 	//a.doFormat()
 	go a.doRunAsync()
@@ -70,6 +107,8 @@ func (a *Application) doRunAsyncComplete() {
 func (a *Application) onHashChange(h *hash.Hash) {
 	defer a.wantRerender("onHashChange")
 
+	a.applyViewerState(h)
+
 	if a.isLoading || h.ID == "" {
 		return
 	}
@@ -79,6 +118,178 @@ func (a *Application) onHashChange(h *hash.Hash) {
 	// }
 }
 
+// listingArtistID returns the ID of the artist whose program
+// is shown in the listing: either the one from the URL hash,
+// or the first artist that started drawing
+func (a *Application) listingArtistID() string {
+	if a.Hash != nil && a.Hash.ID != "" {
+		return a.Hash.ID
+	}
+	return a.firstArtistID
+}
+
+func (a *Application) addLogEntry(id string, kind int, text string) {
+	a.logEntries = append(a.logEntries, log.Entry{
+		Time:     time.Now(),
+		ArtistID: id,
+		Kind:     kind,
+		Text:     text,
+	})
+	if n := len(a.logEntries); n > maxLogEntries {
+		a.logEntries = a.logEntries[n-maxLogEntries:]
+	}
+	a.wantRerender("addLogEntry")
+}
+
+// LogError adds a parse or connection error to the activity log;
+// id is empty for errors not related to a particular artist
+func (a *Application) LogError(id string, err error) {
+	kind := log.ConnError
+	if _, ok := err.(*draw.ParseError); ok {
+		kind = log.ParseError
+	}
+	a.addLogEntry(id, kind, err.Error())
+}
+
+func (a *Application) onJoin(id, name string) {
+	if a.artistNames == nil {
+		a.artistNames = make(map[string]string)
+	}
+	a.artistNames[id] = name
+	a.artistOrder = append(a.artistOrder, id)
+	a.addLogEntry(id, log.Join, "joined the board")
+}
+
+func (a *Application) onAction(id string, act *draw.Action) {
+	a.addLogEntry(id, log.Action, act.Cmd)
+
+	if a.programs == nil {
+		a.programs = make(map[string][]string)
+		a.current = make(map[string]int)
+	}
+
+	if a.firstArtistID == "" {
+		a.firstArtistID = id
+	}
+
+	if act.Line > 0 {
+		lines := a.programs[id]
+		for len(lines) < act.Line {
+			lines = append(lines, "")
+		}
+		lines[act.Line-1] = act.Cmd
+		a.programs[id] = lines
+	}
+	a.current[id] = act.Line
+}
+
+func (a *Application) onInspect(id string) {
+	if id == a.inspected {
+		return
+	}
+	a.inspected = id
+	a.wantRerender("onInspect")
+}
+
+func setRoomTitle(room string) {
+	if room != "" {
+		vecty.SetTitle(title + ": " + room)
+		return
+	}
+	vecty.SetTitle(title)
+}
+
+// applyViewerState sets up the board the way the URL hash describes;
+// the viewer preferences are used for anything the hash doesn't set
+func (a *Application) applyViewerState(h *hash.Hash) {
+	if h.Room == "" {
+		h.Room = a.prefs.Room
+	}
+	setRoomTitle(h.Room)
+
+	switch h.Grid {
+	case hash.GridOn:
+		a.DrawBoard.SetGridVisible(true)
+	case hash.GridOff:
+		a.DrawBoard.SetGridVisible(false)
+	default:
+		a.DrawBoard.SetGridVisible(!a.prefs.GridHidden)
+	}
+
+	speed := a.prefs.Speed
+	if h.Speed > 0 {
+		speed = h.Speed
+	}
+	for _, s := range drawboard.Speeds {
+		if speed == s {
+			a.DrawBoard.SetSpeed(speed)
+		}
+	}
+	a.DrawBoard.SetSpotlight(h.Spotlight)
+	a.DrawBoard.SetFollow(h.Follow)
+	if h.Zoom > 0 {
+		a.DrawBoard.SetZoom(h.Zoom)
+	}
+}
+
+// viewerStateChanged puts the viewer state into the URL hash
+// so that the exact view can be bookmarked or shared,
+// and saves the viewer preferences
+func (a *Application) viewerStateChanged() {
+	if a.hashPending {
+		return
+	}
+	a.hashPending = true
+
+	time.AfterFunc(hashUpdateDelay, func() {
+		a.hashPending = false
+
+		h := a.Hash
+		h.Follow = a.DrawBoard.Following()
+		h.Spotlight = a.DrawBoard.Spotlight()
+		h.Zoom = a.DrawBoard.Zoom()
+		h.Grid = hash.GridOff
+		if a.DrawBoard.GridVisible() {
+			h.Grid = hash.GridOn
+		}
+		h.Speed = a.DrawBoard.Speed()
+		h.Update()
+
+		a.prefs.GridHidden = h.Grid == hash.GridOff
+		a.prefs.Speed = h.Speed
+		a.prefs.Room = h.Room
+		a.prefs.Save()
+	})
+}
+
+func (a *Application) onPrefsChange(p prefs.Prefs) {
+	a.prefs = p
+	a.prefs.Save()
+
+	a.Hash.Room = p.Room
+	setRoomTitle(p.Room)
+	a.DrawBoard.SetGridVisible(!p.GridHidden)
+	a.DrawBoard.SetTheme(p.Theme)
+
+	a.viewerStateChanged()
+	a.wantRerender("onPrefsChange")
+}
+
+func (a *Application) onFollow(id string) {
+	a.DrawBoard.SetFollow(id)
+	a.viewerStateChanged()
+}
+
+func (a *Application) onSpotlight(id string) {
+	a.DrawBoard.SetSpotlight(id)
+	a.viewerStateChanged()
+}
+
+func (a *Application) onRangesChange(r ranges.Ranges) {
+	a.Hash.ID = a.listingArtistID()
+	a.Hash.SetRanges(r.String())
+}
+
 func (a *Application) doLoad(id string) {
 	if id == a.snippetID || id == "" {
 		return
@@ -117,11 +328,25 @@ func (a *Application) doLoadAsyncComplete(id string) {
 func (a *Application) Mount() {
 	switch a.Hash.ID {
 	case "":
+		a.applyViewerState(a.Hash)
 	default:
 		a.onHashChange(a.Hash)
 	}
 
 	fmt.Println("Mounted")
+	a.isMounted = true
+	a.DrawBoard.Recorder = draw.NewRecorder(&a.session)
+	a.DrawBoard.SetTheme(a.prefs.Theme)
+	a.DrawBoard.OnError = a.LogError
+	a.DrawBoard.OnJoin = a.onJoin
+	a.DrawBoard.OnAction = a.onAction
+	a.DrawBoard.OnPlaybackChange = func() {
+		a.viewerStateChanged()
+		a.wantRerender("OnPlaybackChange")
+	}
+	a.DrawBoard.OnInspect = a.onInspect
+	a.DrawBoard.OnFollowChange = func(string) { a.viewerStateChanged() }
+	a.DrawBoard.OnViewportChange = a.viewerStateChanged
 	a.doRun()
 }
 
@@ -132,6 +357,7 @@ func (a *Application) Unmount() {
 // Render renders the application
 func (a *Application) Render() vecty.ComponentOrHTML {
 	if a.Hash == nil {
+		a.prefs = prefs.Load()
 		a.Hash = hash.New(a.onHashChange)
 	}
 
@@ -139,13 +365,31 @@ func (a *Application) Render() vecty.ComponentOrHTML {
 		vecty.Markup(
 			vecty.MarkupIf(util.IsSafari(), vecty.Class("safari")),
 			vecty.MarkupIf(util.IsIOS(), vecty.Class("ios")),
-			vecty.MarkupIf(a.isDrawingMode, vecty.Class("drawingmode")),
+			vecty.MarkupIf(a.isDrawingMode, vecty.Class("drawingmode", "withlisting")),
+			vecty.Class(a.prefs.Theme),
 		),
 		elem.Div(
 			vecty.Markup(
 				vecty.Class("header"),
 			),
+			vecty.If(a.isDrawingMode, a.renderPlaybackControls()),
+			elem.Div(
+				vecty.Markup(
+					vecty.Class("settings"),
+				),
+				elem.Button(
+					vecty.Markup(
+						vecty.MarkupIf(a.showSettings, vecty.Class("active")),
+						event.Click(func(*vecty.Event) {
+							a.showSettings = !a.showSettings
+							a.wantRerender("showSettings")
+						}),
+					),
+					vecty.Text("Settings"),
+				),
+			),
 		),
+		vecty.If(a.showSettings, a.renderSettings()),
 		elem.Div(
 			vecty.Markup(
 				vecty.Class("body-wrapper"),
@@ -154,14 +398,194 @@ func (a *Application) Render() vecty.ComponentOrHTML {
 				vecty.Markup(
 					vecty.Class("content-wrapper"),
 				),
+				vecty.If(a.isDrawingMode, a.DrawBoard),
+				vecty.If(a.isDrawingMode && a.inspected != "", a.renderInspector()),
+				vecty.If(a.isDrawingMode, elem.Div(
+					vecty.Markup(
+						vecty.Class("listing-wrapper"),
+					),
+					a.renderLayers(),
+					a.renderListing(),
+				)),
 			),
 			elem.Div(
 				vecty.Markup(
 					vecty.Class("log-wrapper"),
 				),
+				&log.Log{
+					Entries:  a.logEntries,
+					Names:    a.artistNames,
+					Order:    a.artistOrder,
+					OnSelect: a.DrawBoard.Highlight,
+				},
 			),
 		),
+	)
+}
+
+// withBoardFocus wraps the playback control handler
+// so that the board gets keyboard focus back after a click
+func (a *Application) withBoardFocus(f func()) func(e *vecty.Event) {
+	return func(e *vecty.Event) {
+		f()
+		a.DrawBoard.Focus()
+	}
+}
+
+func (a *Application) onSpeedChange(e *vecty.Event) {
+	speed, err := strconv.ParseFloat(e.Get("target").Get("value").String(), 64)
+	if err != nil {
+		return
+	}
+	a.DrawBoard.SetSpeed(speed)
+	a.DrawBoard.Focus()
+}
+
+func (a *Application) onSaveSessionClick(e *vecty.Event) {
+	filename := "session-" + time.Now().Format("2006-01-02-1504") + ".jsonl"
+	download.Save(filename, "application/x-ndjson", a.session.String())
+}
 
-		vecty.If(a.isDrawingMode, a.DrawBoard),
+func (a *Application) renderPlaybackControls() *vecty.HTML {
+	pauseTitle := "Pause"
+	if a.DrawBoard.Paused() {
+		pauseTitle = "Resume"
+	}
+
+	var speeds vecty.List
+	for _, speed := range drawboard.Speeds {
+		speeds = append(speeds, elem.Option(
+			vecty.Markup(
+				vecty.Property("value", strconv.FormatFloat(speed, 'f', -1, 64)),
+				vecty.Property("selected", speed == a.DrawBoard.Speed()),
+			),
+			vecty.Text(strconv.FormatFloat(speed, 'f', -1, 64)+"×"),
+		))
+	}
+
+	return elem.Div(
+		vecty.Markup(
+			vecty.Class("menu"),
+		),
+		elem.Button(
+			vecty.Markup(
+				event.Click(a.withBoardFocus(a.DrawBoard.TogglePause)),
+			),
+			vecty.Text(pauseTitle+" "),
+			vecty.Tag("cmd", vecty.Text("Space")),
+		),
+		elem.Button(
+			vecty.Markup(
+				event.Click(a.withBoardFocus(a.DrawBoard.Step)),
+			),
+			vecty.Text("Step "),
+			vecty.Tag("cmd", vecty.Text(".")),
+		),
+		elem.Button(
+			vecty.Markup(
+				event.Click(a.withBoardFocus(a.DrawBoard.Restart)),
+			),
+			vecty.Text("Restart "),
+			vecty.Tag("cmd", vecty.Text("R")),
+		),
+		elem.Span(
+			vecty.Markup(
+				vecty.Class("title"),
+			),
+			vecty.Text("Speed: "),
+			elem.Select(
+				vecty.Markup(
+					event.Change(a.onSpeedChange),
+				),
+				speeds,
+			),
+		),
+		elem.Button(
+			vecty.Markup(
+				event.Click(a.onSaveSessionClick),
+			),
+			vecty.Text("Save session"),
+		),
 	)
 }
+
+func (a *Application) renderSettings() *settings.Settings {
+	p := a.prefs
+	p.GridHidden = !a.DrawBoard.GridVisible()
+	p.Room = a.Hash.Room
+
+	return &settings.Settings{
+		Prefs:    p,
+		OnChange: a.onPrefsChange,
+		OnClose: func() {
+			a.showSettings = false
+			a.wantRerender("showSettings")
+		},
+	}
+}
+
+func (a *Application) renderLayers() *layers.Layers {
+	var list []layers.Layer
+	for _, id := range a.artistOrder {
+		list = append(list, layers.Layer{
+			ID:      id,
+			Name:    a.artistNames[id],
+			Visible: a.DrawBoard.LayerVisible(id),
+		})
+	}
+
+	return &layers.Layers{
+		Grid:   a.DrawBoard.GridVisible(),
+		Layers: list,
+		Solo:   a.DrawBoard.Solo(),
+		OnGridChange: func(visible bool) {
+			a.DrawBoard.SetGridVisible(visible)
+			a.viewerStateChanged()
+			a.wantRerender("OnGridChange")
+		},
+		OnVisibleChange: func(id string, visible bool) {
+			a.DrawBoard.SetLayerVisible(id, visible)
+			a.wantRerender("OnVisibleChange")
+		},
+		OnSoloChange: func(id string) {
+			a.DrawBoard.SetSolo(id)
+			a.wantRerender("OnSoloChange")
+		},
+		OnClear: a.DrawBoard.ClearLayer,
+	}
+}
+
+func (a *Application) renderInspector() *inspector.Inspector {
+	return &inspector.Inspector{
+		ID: a.inspected,
+		Inspect: func(id string) (inspector.Info, bool) {
+			info, ok := a.DrawBoard.Inspect(id)
+			return inspector.Info(info), ok
+		},
+		OnFollow: func(follow bool) {
+			if follow {
+				a.onFollow(a.inspected)
+				return
+			}
+			a.onFollow("")
+		},
+		OnSpotlight: func(spotlight bool) {
+			if spotlight {
+				a.onSpotlight(a.inspected)
+				return
+			}
+			a.onSpotlight("")
+		},
+		OnClose: func() { a.onInspect("") },
+	}
+}
+
+func (a *Application) renderListing() *editor.Editor {
+	id := a.listingArtistID()
+	return &editor.Editor{
+		Lines:          a.programs[id],
+		Current:        a.current[id],
+		Ranges:         ranges.New(a.Hash.Ranges),
+		OnRangesChange: a.onRangesChange,
+	}
+}
@@ -0,0 +1,270 @@
+package drawboard
+
+import (
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
+	"github.com/iafan/goplayspace/client/draw"
+)
+
+// actor represents a single gopher on the board; all coordinates
+// are in board units (steps), with Y axis pointing down
+type actor struct {
+	gopher *js.Object
+	tag    *js.Object // name tag
+	layer  *layer
+	hidden bool
+	order  int // position in the order the actors joined the board
+
+	tint    string        // gopher color
+	wearing *draw.Costume // nil for the default costume
+
+	startX     float64
+	startY     float64
+	startAngle float64
+
+	targetX     float64
+	targetY     float64
+	targetAngle float64
+
+	motion *motion // the way the gopher moves during the current action
+
+	startTime  time.Time
+	targetTime time.Time
+	busy       bool // an action is in progress
+
+	x, y     float64 // position relative to the initial one
+	angle    float64
+	initialX float64 // initial position relative to the center of the board
+	initialY float64
+
+	// turtle keeps the pen and fill state between actions
+	turtle *draw.Turtle
+
+	Actions draw.Actor `vecty:"prop"`
+
+	// queue keeps the actions fetched but not started yet; history keeps
+	// all the actions started so far, so that the drawing can be restarted
+	// from the beginning
+	queue     []*draw.Action
+	history   []*draw.Action
+	replayPos int
+
+	stepsLeft int  // actions left to execute in single-step mode
+	holding   bool // waiting for the single step to complete on other actors
+
+	// segments is the display list of everything the actor has drawn,
+	// used to repaint the board, and painted is the number of the segments
+	// completed on the actor (or shared drawing) layer; poses keeps
+	// the gopher position over time for the timeline
+	segments []segment
+	painted  int
+	poses    []pose
+
+	// the pose the gopher is shown in, if it's shown at all
+	shownPose pose
+	shown     bool
+}
+
+// doSubStep moves the gopher to the pos part (0..1) of the current motion;
+// the pose is recorded once the motion is complete
+func (b *actor) doSubStep(db *DrawBoard, pos float64) {
+	p := b.motion.at(db, pos)
+	if pos >= 1 {
+		b.x, b.y, b.angle = b.targetX, b.targetY, b.targetAngle
+		p.t = b.motion.end
+		b.poses = append(b.poses, p)
+	} else {
+		b.x, b.y, b.angle = p.x-b.initialX, p.y-b.initialY, p.angle
+	}
+
+	db.placeGopher(b, p)
+	db.followGopher(b, p)
+}
+
+// nextAction returns the next action to execute; after the board
+// has been restarted, the recorded history is replayed first
+func (b *actor) nextAction(db *DrawBoard) (*draw.Action, bool) {
+	if b.replayPos < len(b.history) {
+		a := b.history[b.replayPos]
+		b.replayPos++
+		return a, true
+	}
+
+	if len(b.queue) == 0 {
+		return nil, false
+	}
+	a := b.queue[0]
+	b.queue = b.queue[1:]
+	b.history = append(b.history, a)
+	b.replayPos++
+	return a, true
+}
+
+// newTurtle returns the turtle for the actor starting at the x, y point
+// relative to the center of the board
+func newTurtle(db *DrawBoard, x, y float64) *draw.Turtle {
+	t := draw.NewTurtle()
+	t.Board = draw.NewBoard(x, y, db.edge)
+	return t
+}
+
+// reset moves the actor back to its initial state
+// so that its history can be replayed
+func (b *actor) reset(db *DrawBoard) {
+	b.x, b.y, b.angle = 0, 0, 0
+	b.startX, b.startY, b.startAngle = 0, 0, 0
+	b.targetX, b.targetY, b.targetAngle = 0, 0, 0
+	b.motion = nil
+	b.startTime = time.Time{}
+	b.targetTime = time.Time{}
+	b.busy = false
+	b.turtle = newTurtle(db, b.initialX, b.initialY)
+	b.setCostume(db, draw.DefaultCostume)
+	b.replayPos = 0
+	b.stepsLeft = 0
+	b.holding = false
+	b.segments = nil
+	b.painted = 0
+	b.poses = nil
+}
+
+// progress returns the part of the current action done by the moment t
+func (b *actor) progress(t time.Time) float64 {
+	total := b.targetTime.Sub(b.startTime)
+	if total <= 0 {
+		return 1
+	}
+	return float64(t.Sub(b.startTime)) / float64(total)
+}
+
+// advance moves the gopher along the current action as of the board time,
+// and starts the next actions once it's complete; it returns false
+// if the actor has nothing to do until it gets new actions
+// or the board is resumed
+func (b *actor) advance(db *DrawBoard) bool {
+	t := db.clock.Now()
+	accelerate := db.accelerate && !db.clock.paused
+
+	for i := 0; i < maxActionsPerFrame; i++ {
+		if b.busy {
+			if b.targetTime.After(t) {
+				if !accelerate {
+					b.doSubStep(db, b.progress(t))
+					return !db.clock.paused
+				}
+				b.finishEarly(db.timelineAt(t))
+			}
+			b.doSubStep(db, 1)
+			b.busy = false
+
+			// accelerated actors complete a single action per frame
+			if accelerate {
+				return true
+			}
+		}
+
+		if !b.startAction(db, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// startAction starts the next action at the moment t; actions that
+// take no time are completed right away. It returns false if there's
+// no action to start.
+func (b *actor) startAction(db *DrawBoard, t time.Time) bool {
+	b.startX = b.x
+	b.startY = b.y
+	b.startAngle = b.angle
+
+	b.startTime = t
+	b.targetTime = t
+
+	if !db.canStartAction(b) {
+		return false
+	}
+
+	a, ok := b.nextAction(db)
+	if !ok {
+		db.hold(b)
+		return false
+	}
+	if db.OnAction != nil {
+		db.OnAction(b.Actions.ID(), a)
+	}
+
+	// the turtle calculates where the action leads
+	// (and the pen state after it) without animation
+	turtle := b.turtle
+	turtle.X, turtle.Y, turtle.Angle = b.startX, b.startY, b.startAngle
+	path := turtle.Apply(a)
+
+	switch a.Kind {
+	case draw.Color, draw.Width, draw.PenUp, draw.PenDown,
+		draw.FillColor, draw.BeginFill,
+		draw.Dash, draw.Opacity, draw.Cap, draw.Gradient, draw.Speed, draw.Edge:
+		return true
+	case draw.SetCostume:
+		b.setCostume(db, a.SVal)
+		return true
+	case draw.Say:
+		db.addSpeechBubble(b.x+b.initialX, b.y+b.initialY, a.SVal)
+		return true
+	case draw.Dot, draw.EndFill:
+		now := db.timelineAt(t)
+		for _, s := range path {
+			b.addSegment(now, now, s.Translate(b.initialX, b.initialY))
+		}
+		return true
+	}
+
+	b.targetX = turtle.X
+	b.targetY = turtle.Y
+	b.targetAngle = turtle.Angle
+
+	delay := turtle.Duration(a, path, b.targetAngle != b.startAngle)
+	b.targetTime = t.Add(delay)
+	b.busy = true
+
+	start := db.timelineAt(t)
+	m := &motion{
+		t:          start,
+		end:        start + delay,
+		fromX:      b.startX + b.initialX,
+		fromY:      b.startY + b.initialY,
+		fromAngle:  b.startAngle,
+		toX:        b.targetX + b.initialX,
+		toY:        b.targetY + b.initialY,
+		toAngle:    b.targetAngle,
+		path:       make([]draw.Segment, len(path)),
+		dist:       draw.PathLength(path),
+		followPath: draw.FollowsPath(a, path),
+		costume:    b.costume(),
+	}
+	b.motion = m
+
+	// the display list gets a segment per path element,
+	// traced one after another as the gopher walks along
+	dist := 0.0
+	for i, s := range path {
+		s = s.Translate(b.initialX, b.initialY)
+		m.path[i] = s
+		l := s.Length()
+		if s.Color != "" {
+			b.addSegment(m.timeAt(dist), m.timeAt(dist+l), s)
+		}
+		dist += l
+	}
+
+	p := m.at(db, 0)
+	p.t, p.motion = start, m
+	b.poses = append(b.poses, p)
+
+	// stop accelerating only after the 'Step' event; accelerate through others
+	if a.Kind == draw.Step && db.tabDown {
+		db.accelerate = false
+	}
+	return true
+}
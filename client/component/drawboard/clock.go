@@ -0,0 +1,60 @@
+package drawboard
+
+import "time"
+
+// clock provides the board time which all animations are driven by;
+// unlike the wall clock it can be paused, sped up or slowed down
+type clock struct {
+	origin  time.Time     // board time at which the clock was started
+	base    time.Time     // wall time of the last pause/resume/speed change
+	elapsed time.Duration // board time elapsed by the moment of base
+	speed   float64
+	paused  bool
+}
+
+func newClock() *clock {
+	now := time.Now()
+	return &clock{
+		origin: now,
+		base:   now,
+		speed:  1,
+	}
+}
+
+// Elapsed returns the board time elapsed since the clock was started
+func (c *clock) Elapsed() time.Duration {
+	if c.paused {
+		return c.elapsed
+	}
+	return c.elapsed + time.Duration(float64(time.Since(c.base))*c.speed)
+}
+
+// Now returns the current board time
+func (c *clock) Now() time.Time {
+	return c.origin.Add(c.Elapsed())
+}
+
+// Pause stops the board time
+func (c *clock) Pause() {
+	if c.paused {
+		return
+	}
+	c.elapsed = c.Elapsed()
+	c.paused = true
+}
+
+// Resume continues the board time after Pause
+func (c *clock) Resume() {
+	if !c.paused {
+		return
+	}
+	c.base = time.Now()
+	c.paused = false
+}
+
+// SetSpeed sets the board time multiplier
+func (c *clock) SetSpeed(speed float64) {
+	c.elapsed = c.Elapsed()
+	c.base = time.Now()
+	c.speed = speed
+}
@@ -0,0 +1,84 @@
+package drawboard
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/iafan/goplayspace/client/draw"
+)
+
+const costumesURL = "/costumes/costumes.json"
+
+// defaultCostume describes the gopher sprite sheets
+// set (and tinted) by the gopher-<color> CSS classes
+var defaultCostume = &draw.Costume{
+	Name:   draw.DefaultCostume,
+	Frames: walkFrames,
+	Width:  walkFrameSize,
+	Height: walkFrameSize,
+	PivotX: walkFrameSize / 2,
+	PivotY: walkFrameSize / 2,
+}
+
+func loadCostumes() (draw.Costumes, error) {
+	resp, err := http.Get(costumesURL)
+	if err != nil {
+		return nil, fmt.Errorf("error loading costumes: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error loading costumes: %s", resp.Status)
+	}
+
+	return draw.ReadCostumes(resp.Body)
+}
+
+// rotationFrame returns the index of the middle frame
+// shown while standing or turning
+func rotationFrame(c *draw.Costume) int {
+	return (c.Frames - 1) / 2
+}
+
+// walkFrame returns the walk animation frame after walking dist px
+func walkFrame(c *draw.Costume, dist float64) int {
+	// we move back-forth between frames rather than cycle
+	virtualFrames := c.Frames*2 - 1
+
+	// offset frame number by rotationFrame index
+	frame := (int(dist/walkFrameDistance) + rotationFrame(c)) % virtualFrames
+
+	if frame > c.Frames-1 {
+		frame = virtualFrames - frame
+	}
+	return frame
+}
+
+// costume returns the costume the actor is wearing
+func (b *actor) costume() *draw.Costume {
+	if b.wearing == nil {
+		return defaultCostume
+	}
+	return b.wearing
+}
+
+// setCostume puts the costume with the given name on;
+// the gopher color only applies to the default costume
+func (b *actor) setCostume(db *DrawBoard, name string) {
+	classList := b.gopher.Get("classList")
+	if name == draw.DefaultCostume {
+		b.wearing = nil
+		classList.Call("add", "gopher-"+b.tint)
+		return
+	}
+
+	c, ok := db.costumes[name]
+	if !ok {
+		if db.OnError != nil {
+			db.OnError(b.Actions.ID(), fmt.Errorf("unknown costume: %q", name))
+		}
+		return
+	}
+	b.wearing = c
+	classList.Call("remove", "gopher-"+b.tint)
+}
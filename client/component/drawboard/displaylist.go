@@ -0,0 +1,547 @@
+package drawboard
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/iafan/goplayspace/client/draw"
+	"github.com/iafan/goplayspace/client/js/canvas"
+)
+
+// segment is a single stroked line, arc, dot or fill of the drawing,
+// in board units; the pen traces it from the moment t of the timeline
+// to the moment end (dots and fills appear at once)
+type segment struct {
+	t, end time.Duration
+	draw.Segment
+}
+
+// at returns the part of the segment traced by the moment t;
+// ok is false if the pen hasn't started tracing it by then
+func (s segment) at(t time.Duration) (part segment, ok bool) {
+	switch {
+	case t >= s.end:
+		return s, true
+	case t <= s.t:
+		return s, false
+	}
+	k := float64(t-s.t) / float64(s.end-s.t)
+	s.Segment = s.Slice(0, s.Length()*k)
+	return s, true
+}
+
+// pose is the gopher position (in board units), heading
+// and walk animation frame at a given moment of the timeline;
+// the gopher follows the motion (if any) from there
+type pose struct {
+	t      time.Duration
+	x, y   float64
+	angle  float64
+	frame  int
+	motion *motion
+}
+
+// motion is the way the gopher moves during an action, from the moment t
+// of the timeline to the moment end, in board units
+type motion struct {
+	t, end time.Duration
+
+	fromX, fromY, fromAngle float64
+	toX, toY, toAngle       float64
+
+	path       []draw.Segment
+	dist       float64 // path length
+	followPath bool    // the gopher heading follows the path (for shapes)
+	costume    *draw.Costume
+}
+
+// at returns the gopher pose after completing the pos part (0..1)
+// of the motion
+func (m *motion) at(db *DrawBoard, pos float64) pose {
+	dist := m.dist * pos
+
+	p := pose{
+		x:     m.fromX,
+		y:     m.fromY,
+		angle: (m.toAngle-m.fromAngle)*pos + m.fromAngle,
+		frame: walkFrame(m.costume, dist*db.stepSize),
+	}
+	switch {
+	case pos >= 1:
+		p.x, p.y = m.toX, m.toY
+	case len(m.path) > 0:
+		var heading float64
+		p.x, p.y, heading = draw.PathAt(m.path, dist)
+		if m.followPath {
+			p.angle = heading
+		}
+	}
+	return p
+}
+
+// timeAt returns the moment the gopher is at the distance d along the path
+func (m *motion) timeAt(d float64) time.Duration {
+	switch {
+	case m.dist == 0:
+		return m.t
+	case d >= m.dist:
+		return m.end
+	}
+	return m.t + time.Duration(float64(m.end-m.t)*d/m.dist)
+}
+
+// progress returns the part of the motion completed by the moment t
+func (m *motion) progress(t time.Duration) float64 {
+	if t >= m.end {
+		return 1
+	}
+	return float64(t-m.t) / float64(m.end-m.t)
+}
+
+// timelineTime returns the board time elapsed since the beginning
+// of the drawing (or since it was last restarted)
+func (b *DrawBoard) timelineTime() time.Duration {
+	return b.clock.Elapsed() - b.timelineStart
+}
+
+// timelineAt returns the moment of the timeline at the board time t
+func (b *DrawBoard) timelineAt(t time.Time) time.Duration {
+	return t.Sub(b.clock.origin) - b.timelineStart
+}
+
+// addSegment adds the segment (in board units) traced by the pen
+// from the moment t to the moment end to the actor's display list;
+// it gets painted in the next animation frame
+func (a *actor) addSegment(t, end time.Duration, s draw.Segment) {
+	a.segments = append(a.segments, segment{t, end, s})
+}
+
+// drawn returns the number of segments in the actor's display list
+// completed by the moment t
+func (a *actor) drawn(t time.Duration) int {
+	return sort.Search(len(a.segments), func(i int) bool {
+		return a.segments[i].end > t
+	})
+}
+
+// finishEarly marks the segments and the motion of the current action
+// as completed at the moment t (e.g. when the action has been accelerated)
+func (a *actor) finishEarly(t time.Duration) {
+	for i := len(a.segments) - 1; i >= 0 && a.segments[i].end > t; i-- {
+		s := &a.segments[i]
+		s.end = t
+		if s.t > t {
+			s.t = t
+		}
+	}
+	if a.motion != nil && a.motion.end > t {
+		a.motion.end = t
+	}
+}
+
+// eachSegment calls fn for every segment the pen has started tracing
+// by the moment t, merging the display lists of all the actors
+// in the order the segments were started
+func (b *DrawBoard) eachSegment(t time.Duration, fn func(a *actor, s segment)) {
+	h := make(segmentHeap, 0, len(b.joined))
+	for _, a := range b.joined {
+		if len(a.segments) > 0 {
+			h = append(h, segmentCursor{a, 0})
+		}
+	}
+	heap.Init(&h)
+
+	for len(h) > 0 {
+		c := &h[0]
+		s := c.a.segments[c.i]
+		if s.t > t || (s.t == t && s.end > t) {
+			return // so are all the other segments left
+		}
+		fn(c.a, s)
+
+		c.i++
+		if c.i == len(c.a.segments) {
+			heap.Pop(&h)
+			continue
+		}
+		heap.Fix(&h, 0)
+	}
+}
+
+// segmentCursor points to a segment in the actor's display list
+type segmentCursor struct {
+	a *actor
+	i int
+}
+
+// segmentHeap keeps the cursors ordered by the time their segments
+// were drawn, and then by the join order; it implements heap.Interface
+type segmentHeap []segmentCursor
+
+func (h segmentHeap) Len() int { return len(h) }
+func (h segmentHeap) Less(i, j int) bool {
+	ti, tj := h[i].a.segments[h[i].i].t, h[j].a.segments[h[j].i].t
+	if ti != tj {
+		return ti < tj
+	}
+	return h[i].a.order < h[j].a.order
+}
+func (h segmentHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *segmentHeap) Push(x interface{}) { *h = append(*h, x.(segmentCursor)) }
+func (h *segmentHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+func (b *DrawBoard) paintSegment(ctx *canvas.CanvasRenderingContext2D, s segment) {
+	x1, y1 := b.toScreen(s.X1, s.Y1)
+
+	ctx.Save()
+	defer ctx.Restore()
+	ctx.SetGlobalAlpha(s.Opacity)
+
+	if s.Dot {
+		ctx.SetFillStyle(b.penColor(s.Color))
+		ctx.BeginPath()
+		ctx.Arc(x1, y1, s.Width*b.view.zoom/2, 0, 2*math.Pi, false)
+		ctx.Fill(draw.NonZero)
+		return
+	}
+
+	if s.Outline != nil {
+		b.paintFill(ctx, s)
+		return
+	}
+
+	ctx.SetLineWidth(s.Width * b.view.zoom)
+	ctx.SetLineCap(s.Cap)
+	b.setStrokeStyle(ctx, s)
+
+	if s.Dash != nil {
+		dash := make([]float64, len(s.Dash))
+		for i, d := range s.Dash {
+			dash[i] = d * b.view.zoom
+		}
+		ctx.SetLineDash(dash)
+		ctx.SetLineDashOffset(s.Offset * b.scale())
+	}
+
+	ctx.BeginPath()
+	if s.Arc {
+		cx, cy := b.toScreen(s.CX, s.CY)
+		ctx.Arc(cx, cy, s.R*b.scale(), s.A1, s.A1+s.Sweep, s.Sweep < 0)
+	} else {
+		x2, y2 := b.toScreen(s.X2, s.Y2)
+		ctx.MoveTo(x1, y1)
+		ctx.LineTo(x2, y2)
+	}
+	ctx.Stroke()
+}
+
+// bounds is a rectangle on the canvas, in px
+type bounds struct {
+	x1, y1, x2, y2 float64
+}
+
+func emptyBounds() bounds {
+	return bounds{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+}
+
+func (r bounds) empty() bool {
+	return r.x1 > r.x2 || r.y1 > r.y2
+}
+
+func (r bounds) add(x, y float64) bounds {
+	return bounds{math.Min(r.x1, x), math.Min(r.y1, y), math.Max(r.x2, x), math.Max(r.y2, y)}
+}
+
+func (r bounds) union(o bounds) bounds {
+	return bounds{math.Min(r.x1, o.x1), math.Min(r.y1, o.y1), math.Max(r.x2, o.x2), math.Max(r.y2, o.y2)}
+}
+
+func (r bounds) overlaps(o bounds) bool {
+	return r.x1 < o.x2 && o.x1 < r.x2 && r.y1 < o.y2 && o.y1 < r.y2
+}
+
+// segmentBounds returns the area of the canvas the segment is painted on
+func (b *DrawBoard) segmentBounds(s segment) bounds {
+	r := emptyBounds()
+	width := 0.0
+	add := func(o draw.Segment) {
+		r = r.add(b.toScreen(o.X1, o.Y1))
+		r = r.add(b.toScreen(o.X2, o.Y2))
+		if o.Arc {
+			r = r.add(b.toScreen(o.CX-o.R, o.CY-o.R))
+			r = r.add(b.toScreen(o.CX+o.R, o.CY+o.R))
+		}
+		width = math.Max(width, o.Width)
+	}
+	add(s.Segment)
+	for _, o := range s.Outline {
+		add(o)
+	}
+
+	// square caps stick out diagonally, plus a pixel for antialiasing
+	pad := width*b.view.zoom*math.Sqrt2/2 + 1
+	return bounds{r.x1 - pad, r.y1 - pad, r.x2 + pad, r.y2 + pad}
+}
+
+// penColor returns the color to paint the segment of the given color with:
+// the default pen color depends on the board theme
+func (b *DrawBoard) penColor(color string) string {
+	if color == draw.DefaultColor {
+		return b.theme.Pen
+	}
+	return color
+}
+
+// setStrokeStyle sets the segment color, or its gradient
+func (b *DrawBoard) setStrokeStyle(ctx *canvas.CanvasRenderingContext2D, s segment) {
+	if s.GradientTo == "" {
+		ctx.SetStrokeStyle(b.penColor(s.Color))
+		return
+	}
+
+	x1, y1 := b.toScreen(s.GX1, s.GY1)
+	x2, y2 := b.toScreen(s.GX2, s.GY2)
+	g := ctx.CreateLinearGradient(x1, y1, x2, y2)
+	g.AddColorStop(0, b.penColor(s.Color))
+	g.AddColorStop(1, s.GradientTo)
+	ctx.SetStrokeStyle(g.Object)
+}
+
+// paintFill fills the outline of the segment and strokes
+// the parts of the outline traced with the pen down on top of it
+func (b *DrawBoard) paintFill(ctx *canvas.CanvasRenderingContext2D, s segment) {
+	ctx.SetFillStyle(b.penColor(s.Color))
+	ctx.BeginPath()
+	for i, o := range s.Outline {
+		if i == 0 {
+			x, y := b.toScreen(o.X1, o.Y1)
+			ctx.MoveTo(x, y)
+		}
+		if o.Arc {
+			cx, cy := b.toScreen(o.CX, o.CY)
+			ctx.Arc(cx, cy, o.R*b.scale(), o.A1, o.A1+o.Sweep, o.Sweep < 0)
+			continue
+		}
+		x, y := b.toScreen(o.X2, o.Y2)
+		ctx.LineTo(x, y)
+	}
+	ctx.ClosePath()
+	ctx.Fill(s.FillRule)
+
+	for _, o := range s.Outline {
+		if o.Color != "" {
+			b.paintSegment(ctx, segment{t: s.t, end: s.end, Segment: o})
+		}
+	}
+}
+
+// repaint clears all the layers and paints the grid
+// and all the segments drawn by the moment t
+func (b *DrawBoard) repaint(t time.Duration) {
+	b.grid.ctx.ClearRect(0, 0, b.w, b.h)
+	b.renderBoardLines()
+
+	for _, a := range b.joined {
+		a.painted = a.drawn(t)
+		if a.layer == nil {
+			continue
+		}
+		a.layer.ctx.ClearRect(0, 0, b.w, b.h)
+		for _, s := range a.segments[:a.painted] {
+			b.paintSegment(a.layer.ctx, s)
+		}
+	}
+	b.repaintDrawing(t)
+	b.repaintFocus(t)
+	b.repaintLive(t)
+
+	b.repaintOverlay(t)
+}
+
+// paintProgress paints the segments completed since the last frame
+// on the actor layers (or the shared drawing and focus layers),
+// and the ones being traced at the moment t on the live layer
+func (b *DrawBoard) paintProgress(t time.Duration) {
+	focused := b.focused()
+	for _, a := range b.joined {
+		n := a.drawn(t)
+		for _, s := range a.segments[a.painted:n] {
+			if a.layer != nil {
+				b.paintSegment(a.layer.ctx, s)
+				continue
+			}
+			b.paintShared(a, s)
+			if a == focused {
+				b.paintSegment(b.focus.ctx, s)
+			}
+		}
+		a.painted = n
+	}
+	b.repaintLive(t)
+}
+
+// repaintLive paints the parts of the segments being traced
+// at the moment t on the live layer; they only get painted
+// on the actor layers once completed, so that the parts
+// traced in each frame don't overlap
+func (b *DrawBoard) repaintLive(t time.Duration) {
+	if b.liveDirty {
+		b.live.ctx.ClearRect(0, 0, b.w, b.h)
+		b.liveDirty = false
+	}
+
+	for _, a := range b.joined {
+		if !b.gopherVisible(a) {
+			continue
+		}
+		for _, s := range a.segments[a.painted:] {
+			part, ok := s.at(t)
+			if !ok {
+				break
+			}
+			if b.dimmed(a) {
+				part = dimSegment(part, spotlightOpacity)
+			}
+			b.paintSegment(b.live.ctx, part)
+			b.liveDirty = true
+		}
+	}
+}
+
+// addPose records the gopher pose at the current moment of the timeline;
+// while the gopher moves, poses are only recorded when each motion starts
+// and ends, the ones in between are calculated from the motion
+func (a *actor) addPose(db *DrawBoard, p pose) {
+	p.t = db.timelineTime()
+	a.poses = append(a.poses, p)
+}
+
+// poseAt returns the gopher pose at the moment t of the timeline;
+// ok is false if the actor hasn't joined the board by then
+func (a *actor) poseAt(db *DrawBoard, t time.Duration) (p pose, ok bool) {
+	i := sort.Search(len(a.poses), func(i int) bool {
+		return a.poses[i].t > t
+	})
+	if i == 0 {
+		return pose{}, false
+	}
+	p = a.poses[i-1]
+	if p.motion != nil {
+		p = p.motion.at(db, p.motion.progress(t))
+	}
+	return p, true
+}
+
+// placeGopher moves the gopher element to the given pose;
+// gopher elements are centered on the board by default
+func (b *DrawBoard) placeGopher(a *actor, p pose) {
+	a.shownPose, a.shown = p, true
+	if b.spriteMode {
+		b.spritesChanged()
+		return
+	}
+
+	x, y := b.toScreen(p.x, p.y)
+	c := a.costume()
+	style := fmt.Sprintf(
+		"transform: translateX(%.2fpx) translateY(%.2fpx) rotate(%.2fdeg) scale(%.3f); "+
+			"background-position-x: %.2fpx;",
+		x-b.w/2, y-b.h/2, p.angle, b.view.zoom,
+		-float64(p.frame)*c.Width,
+	)
+
+	// the default costume is set by the CSS classes
+	if a.wearing != nil {
+		style += fmt.Sprintf(
+			" background-image: url(%s); background-size: %.2fpx %.2fpx;"+
+				" width: %.2fpx; height: %.2fpx; margin-left: %.2fpx; margin-top: %.2fpx;"+
+				" transform-origin: %.2fpx %.2fpx;",
+			c.Image, c.Width*float64(c.Frames), c.Height,
+			c.Width, c.Height, -c.PivotX, -c.PivotY,
+			c.PivotX, c.PivotY,
+		)
+	}
+
+	a.gopher.Call("setAttribute", "style", style)
+	b.placeNameTag(a, p)
+}
+
+// Seeking returns true if the board shows a past moment of the timeline
+func (b *DrawBoard) Seeking() bool {
+	return b.seeking
+}
+
+// Duration returns the length of the timeline
+func (b *DrawBoard) Duration() time.Duration {
+	if b.seeking {
+		return b.seekDuration
+	}
+	return b.timelineTime()
+}
+
+// Position returns the moment of the timeline shown on the board
+func (b *DrawBoard) Position() time.Duration {
+	if b.seeking {
+		return b.seekTime
+	}
+	return b.timelineTime()
+}
+
+// Seek shows the board as it was at the moment t of the timeline;
+// actors are paused until Live is called
+func (b *DrawBoard) Seek(t time.Duration) {
+	if !b.seeking {
+		b.seeking = true
+		b.seekDuration = b.timelineTime()
+		b.pausedBeforeSeek = b.Paused()
+		b.Pause()
+	}
+
+	if t > b.seekDuration {
+		t = b.seekDuration
+	}
+	b.seekTime = t
+	b.repaint(t)
+
+	for _, a := range b.connectedActors {
+		p, ok := a.poseAt(b, t)
+		if !ok {
+			a.gopher.Call("setAttribute", "style", "display: none")
+			a.tag.Call("setAttribute", "style", "display: none")
+			a.shown = false
+			b.spritesChanged()
+			continue
+		}
+		b.placeGopher(a, p)
+	}
+
+	if a, ok := b.connectedActors[b.follow]; ok {
+		if p, ok := a.poseAt(b, t); ok {
+			b.followGopher(a, p)
+		}
+	}
+}
+
+// Live brings the board back from the past moment of the timeline
+// to the current state of the drawing
+func (b *DrawBoard) Live() {
+	if !b.seeking {
+		return
+	}
+
+	b.Seek(b.seekDuration)
+	b.seeking = false
+	if !b.pausedBeforeSeek {
+		b.Resume()
+	}
+	b.requestFrame()
+}
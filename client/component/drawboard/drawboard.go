@@ -11,36 +11,32 @@ import (
 	"github.com/gopherjs/vecty/elem"
 	"github.com/gopherjs/vecty/event"
 	"github.com/iafan/goplayspace/client/draw"
-	"github.com/iafan/goplayspace/client/js/canvas"
 	"github.com/iafan/goplayspace/client/js/document"
 	"github.com/iafan/goplayspace/client/js/window"
+	"github.com/iafan/goplayspace/client/theme"
 	"github.com/iafan/goplayspace/client/util"
 )
 
 const (
-	firstStepDelay = 500 * time.Millisecond
-	stepDelay      = 500 * time.Millisecond
 	// should be longer than `.say-bubble.animate`` CSS animation duration
 	removeBubbleDelay = 5 * time.Second
+	// should be longer than `.gopher.highlight` CSS animation duration
+	highlightDelay = 2 * time.Second
 
 	// when determining the scale of the board, how many cells should be visible
 	// in each direction from the center of the board; the scale is calculated
 	// based on the smallest dimension (width or height)
-	stepsInEachDirection = 15
+	stepsInEachDirection = draw.BoardExtent
 
-	walkFrameDistance = 2                    // distance in px along the path between animation frames
-	walkFrames        = 5                    // total frames in the walk animation
-	rotationFrame     = (walkFrames - 1) / 2 // middle frame index
-	virtualWalkFrames = walkFrames*2 - 1     // we move back-forth between frames rather than cycle
+	walkFrameDistance = 2 // distance in px along the path between animation frames
+	walkFrames        = 5 // total frames in the default walk animation
 	walkFrameSize     = 50
-
-	boardLineWidth    = 1
-	boardStrokeStyle  = "rgba(0, 0, 0, 0.05)"
-	fifthStrokeStyle  = "rgba(0, 0, 0, 0.09)"
-	centerStrokeStyle = "rgba(0, 0, 0, 0.16)"
 )
 
 var (
+	// Speeds lists the available board speed multipliers
+	Speeds = []float64{0.25, 0.5, 1, 2, 4, 8}
+
 	colors = []string{
 		"original",
 		"periwinkle",
@@ -57,49 +53,88 @@ var (
 	}
 )
 
-type actor struct {
-	ctx    *canvas.CanvasRenderingContext2D
-	gopher *js.Object
-
-	startX     float64
-	startY     float64
-	startAngle float64
-
-	targetX     float64
-	targetY     float64
-	targetAngle float64
-	targetDist  float64
-
-	startTime  time.Time
-	targetTime time.Time
-
-	x, y     float64
-	angle    float64
-	color    string
-	width    float64
-	initialX float64
-	initialY float64
-
-	Actions draw.Actor `vecty:"prop"`
-
-	step int
-}
-
 // DrawBoard represents the drawing board with animation logic
 type DrawBoard struct {
 	vecty.Core
-	canvas          *canvas.Canvas
 	canvasWrapper   *js.Object
 	initialized     bool
-	ctx             *canvas.CanvasRenderingContext2D
+	grid            *layer
+	live            *layer // the parts of the segments being traced
+	overlay         *layer
+	sprites         *layer // gophers, once there are too many for elements
+	drawing         *layer // all the drawings, once there are too many actors for a layer each
+	focus           *layer // the drawing of the solo or spotlight actor over the shared drawing
 	connectedActors map[string]*actor
+	joined          []*actor // connected actors in the order they joined
 	actors          draw.ActorsList
 
 	accelerate bool
 	tabDown    bool
 
-	w, h     float64
-	stepSize float64
+	clock        *clock
+	stepping     bool // single-step mode: actors stop after executing one action
+	framePending bool // the next animation frame will advance the actors
+
+	// timeline state
+	timelineStart    time.Duration // board time when the timeline starts
+	timeline         *timeline
+	seeking          bool          // the board shows a past moment of the timeline
+	seekTime         time.Duration // the moment shown
+	seekDuration     time.Duration // timeline length by the moment seeking started
+	pausedBeforeSeek bool
+
+	view     viewport
+	pointers map[int]pointer // active pointers used to pan and pinch zoom
+	tap      *tap
+
+	gridHidden   bool
+	solo         string // ID of the only actor shown; empty if solo mode is off
+	spotlight    string // ID of the only actor not dimmed
+	follow       string // ID of the actor followed by the camera
+	highlighted  string // ID of the actor whose drawing is highlighted
+	highlightSeq int
+
+	spriteMode   bool                    // gophers are painted on the sprite layer
+	spritesDirty bool                    // the sprite layer needs repainting
+	liveDirty    bool                    // the live layer has something painted on it
+	sheets       map[string]*spriteSheet // by image and filter
+
+	edge  string      // default edge mode of the artists
+	theme theme.Board // grid and default pen colors
+
+	costumes draw.Costumes
+
+	// Recorder (if not nil) records the session: actors joining
+	// the board and every action received from them
+	Recorder *draw.Recorder
+
+	// OnError is called on errors related to the actor with the given ID
+	OnError func(id string, err error)
+
+	// OnInspect is called when a gopher is tapped (with its actor ID),
+	// or when the board is tapped elsewhere (with an empty ID)
+	OnInspect func(id string)
+
+	// OnFollowChange is called when follow mode is turned off
+	// because the board has been panned
+	OnFollowChange func(id string)
+
+	// OnViewportChange is called after the board has been zoomed or panned
+	OnViewportChange func()
+
+	// OnJoin is called when a new actor joins the board
+	OnJoin func(id, name string)
+
+	// OnAction is called every time an actor starts executing an action
+	OnAction func(id string, a *draw.Action)
+
+	// OnPlaybackChange is called when the board is paused or resumed,
+	// or when its speed changes
+	OnPlaybackChange func()
+
+	w, h       float64 // canvas size in CSS pixels
+	pixelRatio float64 // device pixels per CSS pixel
+	stepSize   float64
 }
 
 func New(aa draw.ActorsList) *DrawBoard {
@@ -112,15 +147,28 @@ func New(aa draw.ActorsList) *DrawBoard {
 
 	fmt.Println("New Drawboard")
 
-	return &DrawBoard{
+	b := &DrawBoard{
 		connectedActors: make(map[string]*actor),
 		actors:          aa,
+		clock:           newClock(),
+		view:            viewport{zoom: 1},
+		theme:           theme.BoardColors(theme.Light),
+		pointers:        make(map[int]pointer),
+		sheets:          make(map[string]*spriteSheet),
 	}
+	b.timeline = &timeline{board: b}
+	return b
 }
 
 func (b *DrawBoard) pollForActors() {
 	rand.Seed(time.Now().Unix())
 
+	costumes, err := loadCostumes()
+	if err != nil && b.OnError != nil {
+		b.OnError("", err)
+	}
+	b.costumes = costumes
+
 	for {
 		select {
 		case <-time.After(time.Second):
@@ -128,7 +176,6 @@ func (b *DrawBoard) pollForActors() {
 				continue
 			}
 
-			fmt.Println("Checking for more actors")
 			maybeNewActors := b.actors.Actors()
 			for _, newActor := range maybeNewActors {
 				id := newActor.ID()
@@ -142,6 +189,7 @@ func (b *DrawBoard) pollForActors() {
 				el := document.CreateElement("div")
 				el.Set("id", elemID)
 				el.Set("className", "gopher gopher-"+color)
+				el.Get("dataset").Set("actor", id)
 				b.canvasWrapper.Call("appendChild", el)
 
 				spawnableW := int(b.w * 0.6)
@@ -150,85 +198,150 @@ func (b *DrawBoard) pollForActors() {
 				randomX := rand.Intn(spawnableW) - (spawnableW / 2)
 				randomY := rand.Intn(spawnableH) - (spawnableH / 2)
 
-				fmt.Println("rand", randomX, randomY)
-
 				na := &actor{
+					order:    len(b.joined),
+					tint:     color,
 					Actions:  newActor,
-					ctx:      b.canvas.GetContext2D(),
 					gopher:   document.QuerySelector("#gopher" + id),
-					initialX: float64(randomX),
-					initialY: float64(randomY),
+					tag:      b.newNameTag(id, newActor.Name()),
+					initialX: float64(randomX) / b.stepSize,
+					initialY: float64(randomY) / b.stepSize,
 				}
 
-				style := fmt.Sprintf(
-					"transform: translateX(%.2fpx) translateY(%.2fpx);",
-					na.initialX, na.initialY,
-				)
-
-				na.gopher.Call("setAttribute", "style", style)
+				if !b.spriteMode {
+					na.layer = b.addLayer()
+				}
+				na.turtle = newTurtle(b, na.initialX, na.initialY)
 
-				go na.animate(b)
+				p := pose{x: na.initialX, y: na.initialY, frame: rotationFrame(na.costume())}
+				na.addPose(b, p)
+				b.placeGopher(na, p)
+				b.followGopher(na, p)
 
 				b.connectedActors[id] = na
+				b.joined = append(b.joined, na)
+				b.updateLayerVisibility(na)
+				if !b.spriteMode && len(b.connectedActors) > spriteThreshold {
+					b.useSprites()
+				}
+
+				if b.Recorder != nil {
+					if err := b.Recorder.Join(id, newActor.Name()); err != nil {
+						b.stopRecording(err)
+					}
+				}
+
+				if b.OnJoin != nil {
+					b.OnJoin(id, newActor.Name())
+				}
 			}
+
+			b.pollActions()
 		}
 	}
 
 }
 
 func (b *DrawBoard) getDOMNodes() {
-	if b.canvas == nil {
-		c := document.QuerySelector("canvas")
+	if b.grid == nil {
+		c := document.QuerySelector("canvas.grid-layer")
 		if c != nil {
-			b.canvas = &canvas.Canvas{c}
-			b.ctx = b.canvas.GetContext2D()
+			b.grid = newLayer(c)
+			b.live = newLayer(document.QuerySelector("canvas.live-layer"))
+			b.overlay = newLayer(document.QuerySelector("canvas.overlay-layer"))
+			b.sprites = newLayer(document.QuerySelector("canvas.sprite-layer"))
+			b.grid.setVisible(!b.gridHidden)
 			go b.pollForActors()
 		}
 		b.canvasWrapper = document.QuerySelector(".canvas-wrapper")
 	}
 }
 
+// Highlight briefly highlights the gopher of the actor
+// with the given ID and its drawing
+func (b *DrawBoard) Highlight(id string) {
+	a, ok := b.connectedActors[id]
+	if !ok {
+		return
+	}
+
+	b.highlighted = id
+	b.highlightSeq++
+	seq := b.highlightSeq
+	b.repaintOverlay(b.Position())
+	b.spritesChanged()
+
+	classList := a.gopher.Get("classList")
+	classList.Call("remove", "highlight")
+
+	// force a reflow so that the animation restarts
+	// if the gopher is already highlighted
+	_ = a.gopher.Get("offsetWidth")
+
+	classList.Call("add", "highlight")
+	time.AfterFunc(highlightDelay, func() {
+		classList.Call("remove", "highlight")
+		if b.highlightSeq == seq {
+			b.highlighted = ""
+			b.repaintOverlay(b.Position())
+			b.spritesChanged()
+		}
+	})
+}
+
 func (b *DrawBoard) renderBoardLines() {
-	cX := b.w / 2
-	cY := b.h / 2
+	// skip lines that are too close to each other, leaving only
+	// every 5th, 25th etc. line when zoomed out
+	every := 1
+	for b.scale()*float64(every) < minGridSpacing {
+		every = every * 5
+	}
 
-	nX := int(cX/b.stepSize) + 1
-	nY := int(cY/b.stepSize) + 1
+	x1, y1 := b.fromScreen(0, 0)
+	x2, y2 := b.fromScreen(b.w, b.h)
 
-	b.ctx.SetLineWidth(boardLineWidth)
+	ctx := b.grid.ctx
+	ctx.SetLineWidth(b.theme.GridWidth)
 
-	for x := -nX; x <= nX; x++ {
-		b.ctx.SetStrokeStyle(boardStrokeStyle)
-		if x%5 == 0 {
-			b.ctx.SetStrokeStyle(fifthStrokeStyle)
+	for x := int(math.Floor(x1)); x <= int(math.Ceil(x2)); x++ {
+		if x%every != 0 {
+			continue
+		}
+		ctx.SetStrokeStyle(b.theme.Grid)
+		if x%(every*5) == 0 {
+			ctx.SetStrokeStyle(b.theme.FifthGrid)
 		}
 		if x == 0 {
-			b.ctx.SetStrokeStyle(centerStrokeStyle)
+			ctx.SetStrokeStyle(b.theme.CenterGrid)
 		}
-		b.ctx.BeginPath()
-		b.ctx.MoveTo(cX+float64(x)*b.stepSize, 0)
-		b.ctx.LineTo(cX+float64(x)*b.stepSize, b.h)
-		b.ctx.Stroke()
+		sx, _ := b.toScreen(float64(x), 0)
+		ctx.BeginPath()
+		ctx.MoveTo(sx, 0)
+		ctx.LineTo(sx, b.h)
+		ctx.Stroke()
 	}
 
-	for y := -nY; y <= nY; y++ {
-		b.ctx.SetStrokeStyle(boardStrokeStyle)
-		if y%5 == 0 {
-			b.ctx.SetStrokeStyle(fifthStrokeStyle)
+	for y := int(math.Floor(y1)); y <= int(math.Ceil(y2)); y++ {
+		if y%every != 0 {
+			continue
+		}
+		ctx.SetStrokeStyle(b.theme.Grid)
+		if y%(every*5) == 0 {
+			ctx.SetStrokeStyle(b.theme.FifthGrid)
 		}
 		if y == 0 {
-			b.ctx.SetStrokeStyle(centerStrokeStyle)
+			ctx.SetStrokeStyle(b.theme.CenterGrid)
 		}
-		b.ctx.BeginPath()
-		b.ctx.MoveTo(0, cY+float64(y)*b.stepSize)
-		b.ctx.LineTo(b.w, cY+float64(y)*b.stepSize)
-		b.ctx.Stroke()
+		_, sy := b.toScreen(0, float64(y))
+		ctx.BeginPath()
+		ctx.MoveTo(0, sy)
+		ctx.LineTo(b.w, sy)
+		ctx.Stroke()
 	}
 }
 
 // addSpeechBubble shows the animated 'speech bubble'
-// x, y are the center coordinates of the bubble in pixels
-// relative to the center of the board
+// x, y are the center coordinates of the bubble in board units
 func (b *DrawBoard) addSpeechBubble(x, y float64, s string) {
 	el := document.CreateElement("div")
 	el.Set("className", "say-bubble")
@@ -242,13 +355,12 @@ func (b *DrawBoard) addSpeechBubble(x, y float64, s string) {
 		elw := el.Get("offsetWidth").Float()
 		elh := el.Get("offsetHeight").Float()
 
-		cX := b.w / 2
-		cY := b.h / 2
+		cX, cY := b.toScreen(x, y)
 
 		// center the bubble around x, y point
 		style := fmt.Sprintf(
 			"left: %.0fpx; top: %.0fpx",
-			cX+x-elw/2, cY+y-elh/2,
+			cX-elw/2, cY-elh/2,
 		)
 		el.Call("setAttribute", "style", style)
 
@@ -261,155 +373,205 @@ func (b *DrawBoard) addSpeechBubble(x, y float64, s string) {
 	})
 }
 
-func (b *actor) doSubStep(db *DrawBoard, pos float64) {
-	oldX := b.x
-	oldY := b.y
-
-	b.x = (b.targetX-b.startX)*pos + b.startX
-	b.y = (b.targetY-b.startY)*pos + b.startY
-	b.angle = (b.targetAngle-b.startAngle)*pos + b.startAngle
-
-	cX := (db.w / 2) + b.initialX
-	cY := (db.h / 2) + b.initialY
-
-	if b.color != "" {
-		b.ctx.SetLineWidth(b.width)
-		b.ctx.SetStrokeStyle(b.color)
-		b.ctx.BeginPath()
-		b.ctx.MoveTo(cX+oldX, cY+oldY)
-		b.ctx.LineTo(cX+b.x, cY+b.y)
-		b.ctx.Stroke()
+// canStartAction returns false if the actor should wait
+// before starting its next action: while the board is paused,
+// or after it has executed its action in single-step mode
+func (db *DrawBoard) canStartAction(b *actor) bool {
+	if !db.stepping {
+		return !db.clock.paused
 	}
+	if b.stepsLeft > 0 {
+		b.stepsLeft--
+		return true
+	}
+	db.hold(b)
+	return false
+}
 
-	frame := int(b.targetDist*pos/walkFrameDistance) % virtualWalkFrames
-
-	// offset frame number by rotationFrame index
-	frame = (frame + rotationFrame) % virtualWalkFrames
-
-	if frame > walkFrames-1 {
-		frame = virtualWalkFrames - frame
+// hold marks the actor as done with its single step,
+// and pauses the board when all the actors are done
+func (db *DrawBoard) hold(b *actor) {
+	if !db.stepping {
+		return
 	}
+	b.holding = true
+	for _, a := range db.connectedActors {
+		if !a.holding {
+			return
+		}
+	}
+	db.stepping = false
+	db.clock.Pause()
+	db.notifyPlaybackChange()
+}
 
-	bgPos := -frame * walkFrameSize
+func (b *DrawBoard) onRendered() {
+	b.getDOMNodes()
 
-	style := fmt.Sprintf(
-		"transform: translateX(%.2fpx) translateY(%.2fpx) rotate(%.2fdeg); "+
-			"background-position-x: %dpx;",
-		b.x+b.initialX, b.y+b.initialY, b.angle,
-		bgPos,
-	)
+	time.AfterFunc(100*time.Millisecond, b.Focus)
 
-	b.gopher.Call("setAttribute", "style", style)
+	if !b.initialized {
+		b.initialized = true
+		window.AddEventListener("resize", b.onResize)
+		window.OnDevicePixelRatioChange(b.onPixelRatioChange)
+		b.onResize()
+	}
 }
 
-func (b *actor) doStep(db *DrawBoard) {
-	t := time.Now()
+func (b *DrawBoard) notifyPlaybackChange() {
+	if b.OnPlaybackChange != nil {
+		b.OnPlaybackChange()
+	}
+}
 
-	if b.targetTime.IsZero() || b.targetTime.Sub(t) <= 0 || db.accelerate {
-		b.doSubStep(db, 1)
+// Paused returns true if the board is paused
+// (single-step mode also counts as paused)
+func (b *DrawBoard) Paused() bool {
+	return b.clock.paused || b.stepping
+}
 
-		// new step
-		b.step = b.step + 1
+// Pause pauses all the actors
+func (b *DrawBoard) Pause() {
+	b.stepping = false
+	b.clock.Pause()
+	b.notifyPlaybackChange()
+}
 
-		b.startX = b.x
-		b.startY = b.y
-		b.startAngle = b.angle
+// Resume resumes all the actors after Pause or Step
+func (b *DrawBoard) Resume() {
+	b.stepping = false
+	b.clock.Resume()
+	b.notifyPlaybackChange()
+	b.requestFrame()
+}
 
-		b.startTime = t
-		b.targetTime = t
+// TogglePause pauses the board if it's running and resumes it otherwise
+func (b *DrawBoard) TogglePause() {
+	if b.Paused() {
+		b.Resume()
+		return
+	}
+	b.Pause()
+}
 
-		a, ok := b.Actions.Next()
-		if !ok {
-			return
+// Step makes every actor execute a single action
+// and then pauses the board
+func (b *DrawBoard) Step() {
+	now := b.clock.Now()
+	for _, a := range b.connectedActors {
+		a.holding = false
+		a.stepsLeft = 1
+		if a.busy && a.targetTime.After(now) {
+			// finishing the action in progress counts as a step
+			a.stepsLeft = 0
 		}
-		fmt.Println("HERE", a)
-
-		delay := stepDelay
-
-		switch a.Kind {
-		case draw.Step:
-			b.targetTime = t.Add(time.Duration(float64(delay) * a.FVal))
-
-			rad := (-90 + b.angle) * 2 * math.Pi / 360
-			b.targetX = b.startX + math.Cos(rad)*db.stepSize*a.FVal
-			b.targetY = b.startY + math.Sin(rad)*db.stepSize*a.FVal
-
-			// stop accelerating only after the 'Step' event; accelerate through others
-			if db.tabDown {
-				db.accelerate = false
-			}
+	}
+	b.stepping = true
+	b.clock.Resume()
+	b.notifyPlaybackChange()
+	b.requestFrame()
+}
 
-		case draw.Left:
-			b.targetTime = t.Add(delay)
-			b.targetAngle = b.startAngle - a.FVal // sign inverted to match clock-wise CSS rotation
-		case draw.Right:
-			b.targetTime = t.Add(delay)
-			b.targetAngle = b.startAngle + a.FVal // sign inverted to match clock-wise CSS rotation
-		case draw.Color:
-			b.color = a.SVal
-			util.Schedule(func() { b.doStep(db) })
-			return
-		case draw.Width:
-			b.width = a.FVal
-			util.Schedule(func() { b.doStep(db) })
-			return
-		case draw.Say:
-			db.addSpeechBubble(b.x+b.initialX, b.y+b.initialY, a.SVal)
-			util.Schedule(func() { b.doStep(db) })
-			return
-		}
+// Speed returns the current board speed multiplier
+func (b *DrawBoard) Speed() float64 {
+	return b.clock.speed
+}
 
-		b.targetDist = math.Sqrt(
-			math.Pow(b.targetX-b.startX, 2) + math.Pow(b.targetY-b.startY, 2),
-		)
+// SetTheme sets the grid and default pen colors
+// of the theme with the given name
+func (b *DrawBoard) SetTheme(name string) {
+	b.theme = theme.BoardColors(name)
+	if b.initialized {
+		b.repaint(b.Position())
 	}
+}
 
-	// calculate current position
-	total := b.targetTime.Sub(b.startTime)  // total duration
-	passed := t.Sub(b.startTime)            // passed duration
-	rel := float64(passed) / float64(total) // passed [0..1]
-	b.doSubStep(db, rel)
-
-	window.RequestAnimationFrame(func() { b.doStep(db) })
+// stopRecording reports the recorder error and stops recording
+// the session, which would be missing events from now on
+func (b *DrawBoard) stopRecording(err error) {
+	b.Recorder = nil
+	if b.OnError != nil {
+		b.OnError("", fmt.Errorf("session recording stopped: %v", err))
+	}
 }
 
-func (b *actor) animate(db *DrawBoard) {
-	for {
-		select {
-		case <-time.After(time.Second):
-			fmt.Println("Animating")
-			// db.getDOMNodes()
+// SetEdge sets the default edge mode for the artists
+// that haven't chosen one with the `edge` command
+func (b *DrawBoard) SetEdge(edge string) {
+	b.edge = edge
+	for _, a := range b.connectedActors {
+		a.turtle.Board.Edge = edge
+	}
+}
 
-			// set defaults
-			b.width = 2
+// SetSpeed sets the board speed multiplier
+func (b *DrawBoard) SetSpeed(speed float64) {
+	b.clock.SetSpeed(speed)
+	b.notifyPlaybackChange()
+}
 
-			b.step = -1
-			//console.Log("Animation started")
-			time.AfterFunc(firstStepDelay, func() {
-				b.doStep(db)
-			})
-		}
+// changeSpeed switches to the next (delta > 0) or previous (delta < 0)
+// speed multiplier from the Speeds list
+func (b *DrawBoard) changeSpeed(delta int) {
+	i := 0
+	for i < len(Speeds)-1 && Speeds[i] < b.clock.speed {
+		i++
 	}
+	i += delta
+	if i < 0 || i >= len(Speeds) {
+		return
+	}
+	b.SetSpeed(Speeds[i])
 }
 
-func (b *DrawBoard) onRendered() {
-	b.getDOMNodes()
+// Restart clears the board and replays all the actions
+// of every actor from the beginning
+func (b *DrawBoard) Restart() {
+	b.Live()
+	b.timelineStart = b.clock.Elapsed()
+
+	for _, a := range b.connectedActors {
+		a.reset(b)
+		p := pose{x: a.initialX, y: a.initialY, frame: rotationFrame(a.costume())}
+		a.addPose(b, p)
+		b.placeGopher(a, p)
+	}
 
-	time.AfterFunc(100*time.Millisecond, func() {
-		document.QuerySelector(".canvas-lightbox").Call("focus")
-	})
+	b.requestFrame()
 
-	if !b.initialized {
-		b.initialized = true
-		window.AddEventListener("resize", b.onResize)
-		b.onResize()
+	if a, ok := b.connectedActors[b.follow]; ok {
+		b.centerOn(a.poses[len(a.poses)-1])
+		return
 	}
+	b.repaint(0)
+}
+
+// Focus moves keyboard focus to the board
+// so that keyboard shortcuts work
+func (b *DrawBoard) Focus() {
+	document.QuerySelector(".canvas-lightbox").Call("focus")
 }
 
 func (b *DrawBoard) handleKeyDown(e *vecty.Event) {
 	switch e.Get("key").String() {
+	case " ":
+		e.Call("preventDefault")
+		b.TogglePause()
+	case ".":
+		b.Step()
+	case "+", "=":
+		b.changeSpeed(1)
+	case "-":
+		b.changeSpeed(-1)
+	case "r":
+		b.Restart()
+	case "f":
+		b.FitAll()
+	case "0":
+		b.ResetZoom()
 	case "Shift":
 		b.accelerate = true
+		b.requestFrame()
 	case "Tab":
 		e.Call("preventDefault")
 		if b.tabDown {
@@ -417,6 +579,7 @@ func (b *DrawBoard) handleKeyDown(e *vecty.Event) {
 		}
 		b.accelerate = true
 		b.tabDown = true
+		b.requestFrame()
 	default:
 	}
 }
@@ -435,14 +598,63 @@ func (b *DrawBoard) handleKeyUp(e *vecty.Event) {
 }
 
 func (b *DrawBoard) onResize() {
-	b.w, b.h = b.canvas.GetNodeSize()
+	b.w, b.h = b.grid.canvas.GetNodeSize()
 	min := b.w
 	if b.h < min {
 		min = b.h
 	}
 	b.stepSize = min / (stepsInEachDirection*2 + 1) // "+1" to add 0.5 steps around
-	b.canvas.SetSize(b.w, b.h)
-	b.renderBoardLines()
+
+	// the canvas backing store is sized in device pixels, while
+	// all the drawing is done in CSS pixels; resizing clears
+	// the canvases, so the drawing is repainted from the display lists
+	b.pixelRatio = window.DevicePixelRatio()
+	b.grid.resize(b.w, b.h, b.pixelRatio)
+	b.live.resize(b.w, b.h, b.pixelRatio)
+	b.overlay.resize(b.w, b.h, b.pixelRatio)
+	b.sprites.resize(b.w, b.h, b.pixelRatio)
+	if b.drawing != nil {
+		b.drawing.resize(b.w, b.h, b.pixelRatio)
+		b.focus.resize(b.w, b.h, b.pixelRatio)
+	}
+	for _, a := range b.connectedActors {
+		if a.layer != nil {
+			a.layer.resize(b.w, b.h, b.pixelRatio)
+		}
+	}
+	b.viewportChanged()
+}
+
+// onPixelRatioChange is called when the window is moved to a screen
+// with a different pixel density, or when the page is zoomed
+func (b *DrawBoard) onPixelRatioChange() {
+	b.onResize()
+	window.OnDevicePixelRatioChange(b.onPixelRatioChange)
+}
+
+func (b *DrawBoard) renderZoomControls() *vecty.HTML {
+	button := func(text, title string, onClick func()) *vecty.HTML {
+		return elem.Button(
+			vecty.Markup(
+				vecty.Property("title", title),
+				event.Click(func(e *vecty.Event) {
+					onClick()
+					b.Focus()
+				}),
+			),
+			vecty.Text(text),
+		)
+	}
+
+	return elem.Div(
+		vecty.Markup(
+			vecty.Class("zoom-controls"),
+		),
+		button("+", "Zoom in (mouse wheel)", b.ZoomIn),
+		button("\u2212", "Zoom out (mouse wheel)", b.ZoomOut),
+		button("1:1", "Reset zoom (0)", b.ResetZoom),
+		button("Fit", "Fit all drawings (F)", b.FitAll),
+	)
 }
 
 // SkipRender implements the vecty.Component interface.
@@ -457,8 +669,33 @@ func (b *DrawBoard) Render() vecty.ComponentOrHTML {
 	elems := []vecty.MarkupOrChild{
 		vecty.Markup(
 			vecty.Class("canvas-wrapper"),
+			event.Wheel(b.onWheel).PreventDefault(),
+			event.PointerDown(b.onPointerDown),
+			event.PointerMove(b.onPointerMove),
+			event.PointerUp(b.onPointerUp),
+			event.PointerCancel(b.onPointerUp),
+		),
+		elem.Canvas(
+			vecty.Markup(
+				vecty.Class("grid-layer"),
+			),
+		),
+		// actor layers are inserted here
+		elem.Canvas(
+			vecty.Markup(
+				vecty.Class("live-layer"),
+			),
+		),
+		elem.Canvas(
+			vecty.Markup(
+				vecty.Class("overlay-layer"),
+			),
+		),
+		elem.Canvas(
+			vecty.Markup(
+				vecty.Class("sprite-layer"),
+			),
 		),
-		elem.Canvas(),
 	}
 
 	return elem.Div(
@@ -469,5 +706,7 @@ func (b *DrawBoard) Render() vecty.ComponentOrHTML {
 			event.KeyUp(b.handleKeyUp),
 		),
 		elem.Div(elems...),
+		b.renderZoomControls(),
+		b.timeline,
 	)
 }
@@ -0,0 +1,93 @@
+package drawboard
+
+import (
+	"math"
+)
+
+const (
+	followZoom = 2 // zoom set when following starts, unless already closer
+
+	// the followed gopher is brought back to the center once it's further
+	// than that (as a fraction of the smaller canvas side) from it, so that
+	// the board isn't repainted on every frame
+	followSlack = 0.2
+
+	spotlightOpacity = 0.2 // opacity of the other artists in spotlight mode
+)
+
+// Following returns the ID of the actor followed by the camera,
+// or an empty string if follow mode is off
+func (b *DrawBoard) Following() string {
+	return b.follow
+}
+
+// SetFollow keeps the gopher of the actor with the given ID in the center
+// of the board, zooming in if needed; the actor may join the board later.
+// An empty ID turns follow mode off.
+func (b *DrawBoard) SetFollow(id string) {
+	b.follow = id
+	if id == "" {
+		return
+	}
+	b.view.zoom = math.Max(b.view.zoom, followZoom)
+
+	a, ok := b.connectedActors[id]
+	if !ok {
+		if b.initialized {
+			b.viewportChanged()
+		}
+		return
+	}
+	if p, ok := a.poseAt(b, b.Position()); ok {
+		b.centerOn(p)
+	}
+}
+
+// stopFollowing turns follow mode off when the board is panned by hand
+func (b *DrawBoard) stopFollowing() {
+	if b.follow == "" {
+		return
+	}
+	b.follow = ""
+	if b.OnFollowChange != nil {
+		b.OnFollowChange("")
+	}
+}
+
+// followGopher re-centers the board on the gopher if it's followed
+// and has strayed from the center
+func (b *DrawBoard) followGopher(a *actor, p pose) {
+	if b.follow == "" || b.follow != a.Actions.ID() || len(b.pointers) > 0 {
+		return
+	}
+	x, y := b.toScreen(p.x, p.y)
+	slack := followSlack * math.Min(b.w, b.h)
+	if math.Abs(x-b.w/2) <= slack && math.Abs(y-b.h/2) <= slack {
+		return
+	}
+	b.centerOn(p)
+}
+
+func (b *DrawBoard) centerOn(p pose) {
+	b.view.centerX, b.view.centerY = p.x, p.y
+	if b.initialized {
+		b.viewportChanged()
+	}
+}
+
+// Spotlight returns the ID of the actor in the spotlight,
+// or an empty string if spotlight mode is off
+func (b *DrawBoard) Spotlight() string {
+	return b.spotlight
+}
+
+// SetSpotlight dims the drawings and gophers of all the actors
+// but the one with the given ID; an empty ID turns spotlight mode off
+func (b *DrawBoard) SetSpotlight(id string) {
+	b.spotlight = id
+	for _, a := range b.connectedActors {
+		b.updateLayerVisibility(a)
+	}
+	b.updateFocus()
+	b.repaintLive(b.Position())
+}
@@ -0,0 +1,96 @@
+package drawboard
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/gopherjs/gopherjs/js"
+	"github.com/iafan/goplayspace/client/draw"
+	"github.com/iafan/goplayspace/client/js/document"
+)
+
+const (
+	maxTapDistance = 5 // in px; pointers moving further pan the board
+	nameTagOffset  = 2 // in px, between the gopher and its name tag
+	recentCommands = 5 // number of the last commands shown by the inspector
+)
+
+// ActorInfo describes the current state of an actor
+type ActorInfo struct {
+	ID   string
+	Name string
+
+	// position relative to the starting point, with the Y axis
+	// pointing up (as in `goto`), and heading (0..360, 0 is up)
+	X, Y    float64
+	Heading float64
+
+	Color   string // empty when the pen is off
+	Width   float64
+	PenUp   bool
+	Costume string
+
+	Queued int      // actions received but not started yet
+	Recent []string // last commands, most recent last
+
+	Followed bool // the camera follows the gopher
+	Spotlit  bool // everything but the actor is dimmed
+}
+
+// Inspect returns the current state of the actor with the given ID
+func (b *DrawBoard) Inspect(id string) (ActorInfo, bool) {
+	a, ok := b.connectedActors[id]
+	if !ok {
+		return ActorInfo{}, false
+	}
+
+	info := ActorInfo{
+		ID:      id,
+		Name:    a.Actions.Name(),
+		X:       a.x,
+		Y:       -a.y,
+		Heading: math.Mod(math.Mod(a.angle, 360)+360, 360),
+		Color:   a.turtle.Color,
+		Width:   a.turtle.Width,
+		PenUp:   a.turtle.PenUp,
+		Costume: a.costume().Name,
+		Queued:  len(a.history) - a.replayPos + len(a.queue),
+
+		Followed: b.follow == id,
+		Spotlit:  b.spotlight == id,
+	}
+	if q, ok := a.Actions.(draw.Queuer); ok {
+		info.Queued += q.Queued()
+	}
+
+	from := a.replayPos - recentCommands
+	if from < 0 {
+		from = 0
+	}
+	for _, act := range a.history[from:a.replayPos] {
+		info.Recent = append(info.Recent, act.Cmd)
+	}
+
+	return info, true
+}
+
+// newNameTag adds the name tag that follows the gopher of the actor
+func (b *DrawBoard) newNameTag(id, name string) *js.Object {
+	el := document.CreateElement("div")
+	el.Set("className", "name-tag")
+	el.Set("textContent", name)
+	el.Get("dataset").Set("actor", id)
+	b.canvasWrapper.Call("appendChild", el)
+	return el
+}
+
+// placeNameTag puts the name tag right under the gopher
+func (b *DrawBoard) placeNameTag(a *actor, p pose) {
+	x, y := b.toScreen(p.x, p.y)
+	c := a.costume()
+	style := fmt.Sprintf(
+		"transform: translateX(%.2fpx) translateY(%.2fpx) translateX(-50%%);",
+		x, y+(c.Height-c.PivotY)*b.view.zoom+nameTagOffset,
+	)
+	a.tag.Call("setAttribute", "style", style)
+}
@@ -0,0 +1,198 @@
+package drawboard
+
+import (
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
+	"github.com/iafan/goplayspace/client/js/canvas"
+	"github.com/iafan/goplayspace/client/js/document"
+)
+
+const (
+	highlightStrokeStyle = "rgba(68, 153, 238, 0.35)"
+	highlightExtraWidth  = 8 // in px, added to the line width
+)
+
+// layer is one of the canvases stacked on the board: the grid
+// at the bottom, then one layer per actor (or a single drawing layer
+// shared by all the actors on big boards), the live layer with
+// the segments being traced, and the overlay on top
+type layer struct {
+	canvas *canvas.Canvas
+	ctx    *canvas.CanvasRenderingContext2D
+}
+
+func newLayer(el *js.Object) *layer {
+	c := &canvas.Canvas{Object: el}
+	return &layer{
+		canvas: c,
+		ctx:    c.GetContext2D(),
+	}
+}
+
+// resize sets the layer size in CSS pixels (this also clears it);
+// the backing store is sized in device pixels
+func (l *layer) resize(w, h, ratio float64) {
+	l.canvas.SetScaledSize(w, h, ratio)
+	l.ctx.SetTransform(ratio, 0, 0, ratio, 0, 0)
+}
+
+func (l *layer) setOpacity(opacity float64) {
+	l.canvas.Get("style").Set("opacity", opacity)
+}
+
+func (l *layer) setVisible(visible bool) {
+	display := "none"
+	if visible {
+		display = ""
+	}
+	l.canvas.Get("style").Set("display", display)
+}
+
+// addLayer creates a new actor (or the shared drawing) layer
+// right under the live layer
+func (b *DrawBoard) addLayer() *layer {
+	el := document.CreateElement("canvas")
+	b.canvasWrapper.Call("insertBefore", el, b.live.canvas.Object)
+	l := newLayer(el)
+	l.resize(b.w, b.h, b.pixelRatio)
+	return l
+}
+
+// GridVisible returns true if the grid layer is shown
+func (b *DrawBoard) GridVisible() bool {
+	return !b.gridHidden
+}
+
+// SetGridVisible shows or hides the grid layer
+func (b *DrawBoard) SetGridVisible(visible bool) {
+	b.gridHidden = !visible
+	if b.grid != nil {
+		b.grid.setVisible(visible)
+	}
+}
+
+// LayerVisible returns true unless the layer of the actor
+// with the given ID has been hidden (solo mode is not taken into account)
+func (b *DrawBoard) LayerVisible(id string) bool {
+	a, ok := b.connectedActors[id]
+	return ok && !a.hidden
+}
+
+// SetLayerVisible shows or hides the drawing and the gopher
+// of the actor with the given ID
+func (b *DrawBoard) SetLayerVisible(id string, visible bool) {
+	a, ok := b.connectedActors[id]
+	if !ok {
+		return
+	}
+	a.hidden = !visible
+	b.updateLayerVisibility(a)
+
+	// only the part of the shared drawing layer covered
+	// by the actor's drawing changes
+	t := b.Position()
+	if a.layer == nil && b.drawing != nil {
+		b.repaintArea(a, t)
+		b.repaintFocus(t)
+	}
+	b.repaintLive(t)
+}
+
+// Solo returns the ID of the only actor shown on the board,
+// or an empty string if solo mode is off
+func (b *DrawBoard) Solo() string {
+	return b.solo
+}
+
+// SetSolo shows only the actor with the given ID on the board;
+// an empty ID turns solo mode off
+func (b *DrawBoard) SetSolo(id string) {
+	b.solo = id
+	for _, a := range b.connectedActors {
+		b.updateLayerVisibility(a)
+	}
+	b.updateFocus()
+	b.repaintLive(b.Position())
+}
+
+// gopherVisible returns true if the drawing and the gopher
+// of the actor are shown, considering solo mode
+func (b *DrawBoard) gopherVisible(a *actor) bool {
+	return !a.hidden && (b.solo == "" || b.solo == a.Actions.ID())
+}
+
+// dimmed returns true if the actor is dimmed in spotlight mode
+func (b *DrawBoard) dimmed(a *actor) bool {
+	return b.spotlight != "" && b.spotlight != a.Actions.ID()
+}
+
+func (b *DrawBoard) updateLayerVisibility(a *actor) {
+	visible := b.gopherVisible(a)
+	dimmed := b.dimmed(a)
+	if a.layer != nil {
+		a.layer.setVisible(visible)
+		opacity := 1.0
+		if dimmed {
+			opacity = spotlightOpacity
+		}
+		a.layer.setOpacity(opacity)
+	}
+
+	for _, el := range []*js.Object{a.gopher, a.tag} {
+		classList := el.Get("classList")
+		classList.Call("toggle", "hidden", !visible)
+		classList.Call("toggle", "dimmed", dimmed)
+	}
+	b.spritesChanged()
+}
+
+// ClearLayer erases everything drawn by the actor with the given ID
+// so far; the actor keeps drawing from where it is
+func (b *DrawBoard) ClearLayer(id string) {
+	a, ok := b.connectedActors[id]
+	if !ok {
+		return
+	}
+
+	// keep the parts of the current action not traced yet
+	t := b.timelineTime()
+	var left []segment
+	for _, s := range a.segments {
+		if s.end <= t {
+			continue
+		}
+		if s.t < t {
+			l := s.Length()
+			s.Segment = s.Slice(l*float64(t-s.t)/float64(s.end-s.t), l)
+			s.t = t
+		}
+		left = append(left, s)
+	}
+	a.segments = left
+	b.repaint(b.Position())
+}
+
+// repaintOverlay clears the overlay and paints
+// the halo around the highlighted actor's drawing
+func (b *DrawBoard) repaintOverlay(t time.Duration) {
+	b.overlay.ctx.ClearRect(0, 0, b.w, b.h)
+
+	a, ok := b.connectedActors[b.highlighted]
+	if !ok {
+		return
+	}
+
+	for _, s := range a.segments {
+		s, ok := s.at(t)
+		if !ok {
+			break
+		}
+		s.Color = highlightStrokeStyle
+		s.GradientTo = ""
+		s.Dash = nil
+		s.Opacity = 1
+		s.Width = s.Width + highlightExtraWidth/b.view.zoom
+		b.paintSegment(b.overlay.ctx, s)
+	}
+}
@@ -0,0 +1,89 @@
+package drawboard
+
+import (
+	"github.com/iafan/goplayspace/client/draw"
+	"github.com/iafan/goplayspace/client/js/window"
+)
+
+// maxActionsPerFrame limits the number of actions an actor can complete
+// in a single frame (e.g. when drawing at the instant speed), so that
+// the board stays responsive; the rest is done in the next frames
+const maxActionsPerFrame = 100
+
+// requestFrame makes sure the next animation frame advances the actors;
+// the frame loop runs only while some actors have something to do
+func (b *DrawBoard) requestFrame() {
+	if b.framePending {
+		return
+	}
+	b.framePending = true
+	window.RequestAnimationFrame(b.frame)
+}
+
+// frame advances every actor once, all at the same board time,
+// paints what they have drawn and repaints the sprite layer if it has changed
+func (b *DrawBoard) frame() {
+	b.framePending = false
+
+	// the board shows the past; actors resume when it gets back live
+	busy := false
+	if !b.seeking {
+		for _, a := range b.joined {
+			if a.advance(b) {
+				busy = true
+			}
+		}
+	}
+
+	if !b.seeking {
+		b.paintProgress(b.timelineTime())
+	}
+	if b.spritesDirty {
+		b.paintSprites()
+	}
+	if busy {
+		b.requestFrame()
+	}
+}
+
+// fetchActions moves the actions received by the actor into its queue
+// and returns true if there were any; getting actions may block (e.g. on
+// HTTP requests), so it's only called from the polling goroutine.
+// Actions are recorded as they arrive, so that session files don't depend
+// on how long they have been waiting in the queue.
+func (b *actor) fetchActions(db *DrawBoard) bool {
+	fetched := false
+	for {
+		a, ok := b.Actions.Next()
+		if !ok {
+			break
+		}
+		b.queue = append(b.queue, a)
+		fetched = true
+
+		if db.Recorder != nil {
+			if err := db.Recorder.Command(b.Actions.ID(), a.Cmd, a.Duration); err != nil {
+				db.stopRecording(err)
+			}
+		}
+
+		// don't ask for more once the actions received so far are taken
+		if q, ok := b.Actions.(draw.Queuer); ok && q.Queued() == 0 {
+			break
+		}
+	}
+	return fetched
+}
+
+// pollActions fetches the actions the actors have received since the last poll
+func (b *DrawBoard) pollActions() {
+	fetched := false
+	for _, a := range b.connectedActors {
+		if a.fetchActions(b) {
+			fetched = true
+		}
+	}
+	if fetched {
+		b.requestFrame()
+	}
+}
@@ -0,0 +1,327 @@
+package drawboard
+
+import (
+	"math"
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
+	"github.com/iafan/goplayspace/client/draw"
+	"github.com/iafan/goplayspace/client/js/canvas"
+	"github.com/iafan/goplayspace/client/js/document"
+)
+
+const (
+	// spriteThreshold is the number of actors above which gophers
+	// are painted on the sprite layer instead of being moved around
+	// as elements, and all the drawings share a single layer,
+	// as both get too slow (and a layer per actor takes too much memory)
+	// on big boards
+	spriteThreshold = 40
+
+	// sprite sheets are rasterized at that many canvas px per costume px,
+	// so that gophers stay sharp when zoomed in a bit
+	spriteResolution = 2
+
+	nameTagFont    = "11px sans-serif"
+	nameTagHeight  = 16 // in px
+	nameTagPadding = 4  // in px, on the left and right
+
+	spriteHighlightStyle = "rgba(255, 204, 0, 0.8)"
+	spriteHighlightWidth = 4 // in px
+)
+
+// gopherTint is the sprite sheet and the filter of a gopher color;
+// they mirror the gopher-<color> CSS classes
+type gopherTint struct {
+	image  string
+	filter string
+}
+
+var gopherTints = map[string]gopherTint{
+	"original":     {"/gopher_walk.svg", ""},
+	"periwinkle":   {"/gopher_walk.svg", "hue-rotate(60deg) saturate(7)"},
+	"yellow":       {"/gopher_walk.svg", "hue-rotate(600deg) saturate(6)"},
+	"red":          {"/gopher_walk.svg", "hue-rotate(180deg) saturate(8)"},
+	"orange":       {"/gopher_walk.svg", "hue-rotate(190deg) saturate(3)"},
+	"lime-green":   {"/gopher_walk.svg", "hue-rotate(260deg) saturate(4)"},
+	"forest-green": {"/gopher_walk_darker.svg", ""},
+	"purple":       {"/gopher_walk_darker.svg", "hue-rotate(160deg) saturate(1.5)"},
+	"gray":         {"/gopher_walk_darker.svg", "hue-rotate(0deg) saturate(0)"},
+	"brown":        {"/gopher_walk_darker.svg", "hue-rotate(290deg) saturate(1.5)"},
+	"fuschia":      {"/gopher_walk_darker.svg", "hue-rotate(220deg) saturate(1.5)"},
+	"hot-pink":     {"/gopher_walk_darker.svg", ""}, // the CSS class filter doesn't apply
+}
+
+// spriteSheet is a costume sprite sheet rasterized (and tinted)
+// for the sprite layer; canvas is nil until the image is loaded
+type spriteSheet struct {
+	canvas *js.Object
+}
+
+// spriteSheet returns the sprite sheet of the costume the actor
+// is wearing, or nil if it's not loaded yet
+func (b *DrawBoard) spriteSheet(a *actor) *js.Object {
+	c := a.costume()
+	image, filter := c.Image, ""
+	if a.wearing == nil {
+		t := gopherTints[a.tint]
+		image, filter = t.image, t.filter
+	}
+
+	key := image + " " + filter
+	if s, ok := b.sheets[key]; ok {
+		return s.canvas
+	}
+
+	s := &spriteSheet{}
+	b.sheets[key] = s
+
+	img := js.Global.Get("Image").New()
+	img.Set("onload", func() {
+		w := c.Width * float64(c.Frames) * spriteResolution
+		h := c.Height * spriteResolution
+
+		el := document.CreateElement("canvas")
+		sheet := &canvas.Canvas{Object: el}
+		sheet.SetSize(w, h)
+		ctx := sheet.GetContext2D()
+		ctx.SetFilter(filter)
+		ctx.DrawImage(img, 0, 0, w, h)
+
+		s.canvas = el
+		b.spritesChanged()
+	})
+	img.Set("src", image)
+	return nil
+}
+
+// useSprites switches to painting gophers on the sprite layer,
+// and merges the actor layers into the shared drawing layer
+func (b *DrawBoard) useSprites() {
+	b.spriteMode = true
+	b.canvasWrapper.Get("classList").Call("add", "sprites")
+
+	b.drawing = b.addLayer()
+	b.focus = b.addLayer()
+	for _, a := range b.connectedActors {
+		if a.layer != nil {
+			a.layer.canvas.Call("remove")
+			a.layer = nil
+		}
+	}
+	b.repaintDrawing(b.Position())
+	b.updateFocus()
+	b.spritesChanged()
+}
+
+// repaintDrawing clears the shared drawing layer (if it's used)
+// and paints all the segments completed by the moment t on it
+func (b *DrawBoard) repaintDrawing(t time.Duration) {
+	if b.drawing == nil {
+		return
+	}
+	b.drawing.ctx.ClearRect(0, 0, b.w, b.h)
+	for _, a := range b.connectedActors {
+		a.painted = a.drawn(t)
+	}
+	b.eachSegment(t, func(a *actor, s segment) {
+		if s.end <= t {
+			b.paintShared(a, s)
+		}
+	})
+}
+
+// paintShared paints the segment of the actor on the shared drawing
+// layer, unless the actor is hidden; solo and spotlight modes don't
+// change what's painted there, see updateFocus
+func (b *DrawBoard) paintShared(a *actor, s segment) {
+	if a.hidden {
+		return
+	}
+	b.paintSegment(b.drawing.ctx, s)
+}
+
+// focused returns the solo (or spotlight) actor shown
+// on the focus layer, or nil if there's none
+func (b *DrawBoard) focused() *actor {
+	id := b.solo
+	if id == "" {
+		id = b.spotlight
+	}
+	a, ok := b.connectedActors[id]
+	if !ok || !b.gopherVisible(a) {
+		return nil
+	}
+	return a
+}
+
+// updateFocus hides the shared drawing layer in solo mode, or dims it
+// in spotlight mode, and repaints the drawing of the solo (or spotlight)
+// actor on the focus layer over it; this way switching the modes
+// only repaints the drawing of a single actor
+func (b *DrawBoard) updateFocus() {
+	if b.drawing == nil {
+		return
+	}
+	b.drawing.setVisible(b.solo == "")
+	opacity := 1.0
+	if b.spotlight != "" {
+		opacity = spotlightOpacity
+	}
+	b.drawing.setOpacity(opacity)
+	b.repaintFocus(b.Position())
+}
+
+// repaintFocus clears the focus layer (if it's used) and paints
+// the segments of the focused actor completed by the moment t on it
+func (b *DrawBoard) repaintFocus(t time.Duration) {
+	if b.focus == nil {
+		return
+	}
+	b.focus.ctx.ClearRect(0, 0, b.w, b.h)
+
+	a := b.focused()
+	if a == nil {
+		return
+	}
+	opacity := 1.0
+	if b.dimmed(a) {
+		opacity = spotlightOpacity
+	}
+	b.focus.setOpacity(opacity)
+	for _, s := range a.segments[:a.drawn(t)] {
+		b.paintSegment(b.focus.ctx, s)
+	}
+}
+
+// repaintArea repaints the part of the shared drawing layer
+// covered by the actor's drawing by the moment t, e.g. after
+// the actor has been hidden or shown
+func (b *DrawBoard) repaintArea(a *actor, t time.Duration) {
+	area := emptyBounds()
+	for _, s := range a.segments[:a.drawn(t)] {
+		area = area.union(b.segmentBounds(s))
+	}
+	if area.empty() {
+		return
+	}
+
+	ctx := b.drawing.ctx
+	ctx.Save()
+	defer ctx.Restore()
+	ctx.BeginPath()
+	ctx.Rect(area.x1, area.y1, area.x2-area.x1, area.y2-area.y1)
+	ctx.Clip()
+	ctx.ClearRect(area.x1, area.y1, area.x2-area.x1, area.y2-area.y1)
+
+	b.eachSegment(t, func(a *actor, s segment) {
+		if s.end <= t && b.segmentBounds(s).overlaps(area) {
+			b.paintShared(a, s)
+		}
+	})
+}
+
+// dimSegment returns the segment (and its outline) with the opacity
+// multiplied by k
+func dimSegment(s segment, k float64) segment {
+	s.Opacity *= k
+	if s.Outline != nil {
+		outline := make([]draw.Segment, len(s.Outline))
+		for i, o := range s.Outline {
+			o.Opacity *= k
+			outline[i] = o
+		}
+		s.Outline = outline
+	}
+	return s
+}
+
+// spritesChanged makes the next animation frame repaint the sprite layer
+func (b *DrawBoard) spritesChanged() {
+	if !b.spriteMode {
+		return
+	}
+	b.spritesDirty = true
+	b.requestFrame()
+}
+
+// paintSprites paints the gophers shown on the board
+// and their name tags on the sprite layer
+func (b *DrawBoard) paintSprites() {
+	b.spritesDirty = false
+
+	ctx := b.sprites.ctx
+	ctx.ClearRect(0, 0, b.w, b.h)
+
+	for _, a := range b.joined {
+		if !a.shown || !b.gopherVisible(a) {
+			continue
+		}
+
+		ctx.Save()
+		if b.dimmed(a) {
+			ctx.SetGlobalAlpha(spotlightOpacity)
+		}
+
+		c := a.costume()
+		p := a.shownPose
+		x, y := b.toScreen(p.x, p.y)
+
+		if b.highlighted == a.Actions.ID() {
+			ctx.SetStrokeStyle(spriteHighlightStyle)
+			ctx.SetLineWidth(spriteHighlightWidth)
+			ctx.BeginPath()
+			ctx.Arc(x, y, math.Max(c.Width, c.Height)/2*b.view.zoom, 0, 2*math.Pi, false)
+			ctx.Stroke()
+		}
+
+		if sheet := b.spriteSheet(a); sheet != nil {
+			ctx.Save()
+			ctx.Translate(x, y)
+			ctx.Rotate(p.angle * math.Pi / 180)
+			ctx.Scale(b.view.zoom, b.view.zoom)
+			ctx.DrawImageRect(sheet,
+				float64(p.frame)*c.Width*spriteResolution, 0,
+				c.Width*spriteResolution, c.Height*spriteResolution,
+				-c.PivotX, -c.PivotY, c.Width, c.Height,
+			)
+			ctx.Restore()
+		}
+
+		b.paintNameTag(ctx, a.Actions.Name(), x, y+(c.Height-c.PivotY)*b.view.zoom+nameTagOffset)
+		ctx.Restore()
+	}
+}
+
+// paintNameTag paints the name centered under the x, y point
+func (b *DrawBoard) paintNameTag(ctx *canvas.CanvasRenderingContext2D, name string, x, y float64) {
+	ctx.SetFont(nameTagFont)
+	ctx.SetTextAlign("center")
+	ctx.SetTextBaseline("middle")
+
+	w := ctx.MeasureText(name) + nameTagPadding*2
+	ctx.SetFillStyle(b.theme.NameTag)
+	ctx.FillRect(x-w/2, y, w, nameTagHeight)
+	ctx.SetFillStyle(b.theme.NameTagText)
+	ctx.FillText(name, x, y+nameTagHeight/2)
+}
+
+// gopherAt returns the ID of the actor whose gopher is painted
+// at x, y (in px) on the sprite layer, or an empty string; of the gophers
+// equally close, the one painted on top wins
+func (b *DrawBoard) gopherAt(x, y float64) string {
+	id, best := "", math.Inf(1)
+	for i := len(b.joined) - 1; i >= 0; i-- {
+		a := b.joined[i]
+		if !a.shown || !b.gopherVisible(a) {
+			continue
+		}
+		c := a.costume()
+		gx, gy := b.toScreen(a.shownPose.x, a.shownPose.y)
+		d := math.Hypot(x-gx, y-gy)
+		if d <= math.Max(c.Width, c.Height)/2*b.view.zoom && d < best {
+			id, best = a.Actions.ID(), d
+		}
+	}
+	return id
+}
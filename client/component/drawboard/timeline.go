@@ -0,0 +1,104 @@
+package drawboard
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gopherjs/vecty"
+	"github.com/gopherjs/vecty/elem"
+	"github.com/gopherjs/vecty/event"
+)
+
+// how often the timeline is updated while the drawing goes on
+const timelineUpdateInterval = 250 * time.Millisecond
+
+// timeline implements the scrubber under the board that allows
+// to see the drawing (and gophers) as it was at any moment of time
+type timeline struct {
+	vecty.Core
+
+	board    *DrawBoard
+	dragging bool
+	mounted  bool
+}
+
+// Mount implements the vecty.Mounter interface.
+func (t *timeline) Mount() {
+	t.mounted = true
+	go t.update()
+}
+
+// Unmount implements the vecty.Unmounter interface.
+func (t *timeline) Unmount() {
+	t.mounted = false
+}
+
+func (t *timeline) update() {
+	for {
+		time.Sleep(timelineUpdateInterval)
+		if !t.mounted {
+			return
+		}
+		if !t.dragging {
+			vecty.Rerender(t)
+		}
+	}
+}
+
+func formatDuration(d time.Duration) string {
+	s := int(d / time.Second)
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+func (t *timeline) onInput(e *vecty.Event) {
+	ms, err := strconv.Atoi(e.Get("target").Get("value").String())
+	if err != nil {
+		return
+	}
+	t.board.Seek(time.Duration(ms) * time.Millisecond)
+	vecty.Rerender(t)
+}
+
+func (t *timeline) onLiveClick(e *vecty.Event) {
+	t.board.Live()
+	t.board.Focus()
+	vecty.Rerender(t)
+}
+
+// Render implements the vecty.Component interface.
+func (t *timeline) Render() vecty.ComponentOrHTML {
+	duration := t.board.Duration()
+	position := t.board.Position()
+
+	return elem.Div(
+		vecty.Markup(
+			vecty.Class("timeline"),
+			vecty.MarkupIf(t.board.Seeking(), vecty.Class("seeking")),
+		),
+		elem.Input(
+			vecty.Markup(
+				vecty.Property("type", "range"),
+				vecty.Property("min", 0),
+				vecty.Property("max", int(duration/time.Millisecond)),
+				vecty.Property("value", int(position/time.Millisecond)),
+				event.Input(t.onInput),
+				event.PointerDown(func(*vecty.Event) { t.dragging = true }),
+				event.PointerUp(func(*vecty.Event) { t.dragging = false }),
+			),
+		),
+		elem.Span(
+			vecty.Markup(
+				vecty.Class("time"),
+			),
+			vecty.Text(formatDuration(position)+" / "+formatDuration(duration)),
+		),
+		elem.Button(
+			vecty.Markup(
+				vecty.Property("disabled", !t.board.Seeking()),
+				event.Click(t.onLiveClick),
+			),
+			vecty.Text("Live"),
+		),
+	)
+}
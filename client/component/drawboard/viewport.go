@@ -0,0 +1,279 @@
+package drawboard
+
+import (
+	"math"
+	"syscall/js"
+
+	"github.com/gopherjs/vecty"
+)
+
+const (
+	minZoom = 0.1
+	maxZoom = 10
+
+	zoomStep        = 1.25  // zoom change for zoom buttons and keys
+	wheelZoomFactor = 0.002 // zoom change per pixel of mouse wheel scroll
+
+	// grid lines closer than that (in px) are skipped
+	// to avoid turning the zoomed out board into a solid fill
+	minGridSpacing = 6
+
+	// how many steps to leave around the drawing when fitting it
+	fitMargin = 1
+)
+
+// viewport defines which part of the board is visible: the board
+// point (centerX, centerY) is shown in the center of the canvas,
+// and a single step takes zoom*stepSize pixels
+type viewport struct {
+	centerX, centerY float64
+	zoom             float64
+}
+
+// pointer is an active mouse / touch pointer over the board, in px
+type pointer struct {
+	x, y float64
+}
+
+// tap is a pointer pressed on the board that hasn't moved yet
+type tap struct {
+	pointer
+	pointerID int
+	actorID   string // the gopher pressed, if any
+}
+
+// scale returns the size of a single step in px
+func (b *DrawBoard) scale() float64 {
+	return b.stepSize * b.view.zoom
+}
+
+// toScreen converts board units into canvas pixel coordinates
+func (b *DrawBoard) toScreen(x, y float64) (float64, float64) {
+	s := b.scale()
+	return b.w/2 + (x-b.view.centerX)*s, b.h/2 + (y-b.view.centerY)*s
+}
+
+// fromScreen converts canvas pixel coordinates into board units
+func (b *DrawBoard) fromScreen(x, y float64) (float64, float64) {
+	s := b.scale()
+	return b.view.centerX + (x-b.w/2)/s, b.view.centerY + (y-b.h/2)/s
+}
+
+// zoomAt multiplies the zoom by factor, keeping the board point
+// at x, y (in px) in place
+func (b *DrawBoard) zoomAt(x, y, factor float64) {
+	bx, by := b.fromScreen(x, y)
+	b.view.zoom = math.Max(minZoom, math.Min(maxZoom, b.view.zoom*factor))
+
+	s := b.scale()
+	b.view.centerX = bx - (x-b.w/2)/s
+	b.view.centerY = by - (y-b.h/2)/s
+}
+
+// pan moves the board by dx, dy px
+func (b *DrawBoard) pan(dx, dy float64) {
+	s := b.scale()
+	b.view.centerX -= dx / s
+	b.view.centerY -= dy / s
+}
+
+// ZoomIn zooms in around the center of the board
+func (b *DrawBoard) ZoomIn() {
+	b.zoomAt(b.w/2, b.h/2, zoomStep)
+	b.viewportChanged()
+}
+
+// ZoomOut zooms out around the center of the board
+func (b *DrawBoard) ZoomOut() {
+	b.zoomAt(b.w/2, b.h/2, 1/zoomStep)
+	b.viewportChanged()
+}
+
+// Zoom returns the board zoom (1 is the default scale)
+func (b *DrawBoard) Zoom() float64 {
+	return b.view.zoom
+}
+
+// SetZoom sets the board zoom, keeping the center of the board in place
+func (b *DrawBoard) SetZoom(zoom float64) {
+	b.view.zoom = math.Max(minZoom, math.Min(maxZoom, zoom))
+	if b.initialized {
+		b.viewportChanged()
+	}
+}
+
+// ResetZoom brings the board back to the default scale
+// with its center in the middle
+func (b *DrawBoard) ResetZoom() {
+	b.view = viewport{zoom: 1}
+	b.viewportChanged()
+}
+
+// FitAll zooms and pans the board so that all the drawings
+// and gophers are visible
+func (b *DrawBoard) FitAll() {
+	t := b.Position()
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	fit := func(x, y float64) {
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+
+	b.eachSegment(t, func(_ *actor, s segment) {
+		fit(s.X1, s.Y1)
+		fit(s.X2, s.Y2)
+		if s.Arc {
+			fit(s.CX-s.R, s.CY-s.R)
+			fit(s.CX+s.R, s.CY+s.R)
+		}
+	})
+
+	for _, a := range b.connectedActors {
+		if p, ok := a.poseAt(b, t); ok {
+			fit(p.x, p.y)
+		}
+	}
+
+	if math.IsInf(minX, 1) {
+		b.ResetZoom()
+		return
+	}
+
+	bw := maxX - minX + fitMargin*2
+	bh := maxY - minY + fitMargin*2
+	zoom := math.Min(b.w/bw, b.h/bh) / b.stepSize
+
+	b.view = viewport{
+		centerX: (minX + maxX) / 2,
+		centerY: (minY + maxY) / 2,
+		zoom:    math.Max(minZoom, math.Min(maxZoom, zoom)),
+	}
+	b.viewportChanged()
+}
+
+// viewportChanged repaints the board and moves the gophers
+// after the zoom, pan or board size has changed
+func (b *DrawBoard) viewportChanged() {
+	t := b.Position()
+	b.repaint(t)
+
+	for _, a := range b.connectedActors {
+		if p, ok := a.poseAt(b, t); ok {
+			b.placeGopher(a, p)
+		}
+	}
+
+	if b.OnViewportChange != nil {
+		b.OnViewportChange()
+	}
+}
+
+// eventPos returns the mouse / touch event position relative
+// to the canvas, in px
+func (b *DrawBoard) eventPos(e *vecty.Event) (x, y float64) {
+	rect := b.grid.canvas.Call("getBoundingClientRect")
+	x = e.Get("clientX").Float() - rect.Get("left").Float()
+	y = e.Get("clientY").Float() - rect.Get("top").Float()
+	return
+}
+
+func (b *DrawBoard) onWheel(e *vecty.Event) {
+	x, y := b.eventPos(e)
+	delta := e.Get("deltaY").Float()
+	if e.Get("deltaMode").Int() != 0 {
+		delta = delta * 20 // lines or pages rather than px
+	}
+	b.zoomAt(x, y, math.Exp(-delta*wheelZoomFactor))
+	b.viewportChanged()
+}
+
+func (b *DrawBoard) onPointerDown(e *vecty.Event) {
+	if e.Get("button").Int() != 0 {
+		return
+	}
+	id := e.Get("pointerId").Int()
+	x, y := b.eventPos(e)
+	b.pointers[id] = pointer{x, y}
+
+	// a single pointer released close to where it was pressed is a tap
+	b.tap = nil
+	if len(b.pointers) == 1 {
+		target := tapTarget(e.Target)
+		if target == "" && b.spriteMode {
+			target = b.gopherAt(x, y)
+		}
+		b.tap = &tap{pointer{x, y}, id, target}
+	}
+
+	b.canvasWrapper.Call("setPointerCapture", id)
+	b.canvasWrapper.Get("classList").Call("add", "panning")
+}
+
+// tapTarget returns the ID of the actor whose gopher (or name tag)
+// is the target of the pointer event, or an empty string
+func tapTarget(target js.Value) string {
+	if target.IsUndefined() || target.IsNull() {
+		return ""
+	}
+	dataset := target.Get("dataset")
+	if dataset.IsUndefined() || dataset.Get("actor").IsUndefined() {
+		return ""
+	}
+	return dataset.Get("actor").String()
+}
+
+func (b *DrawBoard) onPointerMove(e *vecty.Event) {
+	id := e.Get("pointerId").Int()
+	old, ok := b.pointers[id]
+	if !ok {
+		return
+	}
+	x, y := b.eventPos(e)
+	p := pointer{x, y}
+
+	// pinch zoom: the other pointer stays in place
+	// while this one moves
+	if len(b.pointers) == 2 {
+		var other pointer
+		for otherID, o := range b.pointers {
+			if otherID != id {
+				other = o
+			}
+		}
+
+		oldDist := math.Hypot(old.x-other.x, old.y-other.y)
+		dist := math.Hypot(p.x-other.x, p.y-other.y)
+		if oldDist > 0 && dist > 0 {
+			b.zoomAt((old.x+other.x)/2, (old.y+other.y)/2, dist/oldDist)
+		}
+		b.pan((p.x-old.x)/2, (p.y-old.y)/2)
+	} else {
+		b.pan(p.x-old.x, p.y-old.y)
+		b.stopFollowing()
+	}
+
+	b.pointers[id] = p
+	if b.tap != nil && math.Hypot(p.x-b.tap.x, p.y-b.tap.y) > maxTapDistance {
+		b.tap = nil
+	}
+	b.viewportChanged()
+}
+
+func (b *DrawBoard) onPointerUp(e *vecty.Event) {
+	id := e.Get("pointerId").Int()
+	delete(b.pointers, id)
+
+	// tapping a gopher inspects it; tapping the board closes the inspector
+	if t := b.tap; t != nil && t.pointerID == id {
+		b.tap = nil
+		if b.OnInspect != nil {
+			b.OnInspect(t.actorID)
+		}
+	}
+
+	if len(b.pointers) == 0 {
+		b.canvasWrapper.Get("classList").Call("remove", "panning")
+	}
+}
@@ -0,0 +1,111 @@
+package editor
+
+import (
+	"strconv"
+
+	"github.com/gopherjs/vecty"
+	"github.com/gopherjs/vecty/elem"
+	"github.com/gopherjs/vecty/event"
+	"github.com/iafan/goplayspace/client/js/document"
+	"github.com/iafan/goplayspace/client/ranges"
+	"github.com/iafan/goplayspace/client/util"
+)
+
+// Editor implements a read-only program listing which highlights
+// the line currently being executed and allows to select line ranges
+type Editor struct {
+	vecty.Core
+
+	Lines   []string      `vecty:"prop"`
+	Current int           `vecty:"prop"`
+	Ranges  ranges.Ranges `vecty:"prop"`
+
+	OnRangesChange func(r ranges.Ranges) `vecty:"prop"`
+
+	anchor     int // line where the last selection started
+	scrolledTo int // line that was last scrolled into view
+}
+
+// scrollIntoView makes sure the executed line (or the first selected line,
+// until the execution reaches the listing) is visible
+func (e *Editor) scrollIntoView() {
+	sel := ".listing li.exec"
+	line := e.Current
+	if line == 0 {
+		sel = ".listing li.selected"
+		line = e.Ranges.First()
+	}
+
+	if line == 0 || line == e.scrolledTo {
+		return
+	}
+
+	el := document.QuerySelector(sel)
+	if el == nil {
+		return
+	}
+	e.scrolledTo = line
+	el.Call("scrollIntoView", map[string]interface{}{"block": "nearest"})
+}
+
+func (e *Editor) onLineNumberClick(line int) func(ev *vecty.Event) {
+	return func(ev *vecty.Event) {
+		var r ranges.Ranges
+
+		switch {
+		case ev.Get("shiftKey").Bool():
+			r = e.Ranges.Extend(e.anchor, line)
+			if e.anchor == 0 {
+				e.anchor = line
+			}
+		case ev.Get("ctrlKey").Bool() || ev.Get("metaKey").Bool():
+			r = e.Ranges.Toggle(line)
+			e.anchor = line
+		case len(e.Ranges) == 1 && e.Ranges.First() == line && e.Ranges[0].To == line:
+			// clicking on the only selected line deselects it
+			e.anchor = 0
+		default:
+			r = ranges.Ranges{{From: line, To: line}}
+			e.anchor = line
+		}
+
+		e.Ranges = r
+		if e.OnRangesChange != nil {
+			e.OnRangesChange(r)
+		}
+		vecty.Rerender(e)
+	}
+}
+
+// Render implements the vecty.Component interface.
+func (e *Editor) Render() vecty.ComponentOrHTML {
+	util.Schedule(e.scrollIntoView)
+
+	items := make(vecty.List, len(e.Lines))
+	for i, s := range e.Lines {
+		line := i + 1
+		items[i] = elem.ListItem(
+			vecty.Markup(
+				vecty.ClassMap{
+					"exec":     line == e.Current,
+					"selected": e.Ranges.Contains(line),
+				},
+			),
+			elem.Span(
+				vecty.Markup(
+					vecty.Class("lineno"),
+					event.Click(e.onLineNumberClick(line)),
+				),
+				vecty.Text(strconv.Itoa(line)),
+			),
+			vecty.Text(s),
+		)
+	}
+
+	return elem.Div(
+		vecty.Markup(
+			vecty.Class("listing"),
+		),
+		elem.OrderedList(items),
+	)
+}
@@ -0,0 +1,166 @@
+package inspector
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gopherjs/vecty"
+	"github.com/gopherjs/vecty/elem"
+	"github.com/gopherjs/vecty/event"
+)
+
+// refreshInterval is how often the shown state is updated
+const refreshInterval = 250 * time.Millisecond
+
+// Info describes the state of the inspected gopher
+type Info struct {
+	ID      string
+	Name    string
+	X, Y    float64 // relative to the starting point, Y axis up
+	Heading float64 // in degrees, 0 is up
+	Color   string  // empty when the pen is off
+	Width   float64
+	PenUp   bool
+	Costume string
+	Queued  int      // actions waiting to be drawn
+	Recent  []string // last commands, most recent last
+
+	Followed bool // the camera follows the gopher
+	Spotlit  bool // everything but the gopher is dimmed
+}
+
+// Inspector implements the pane that shows the current state
+// of a single gopher; the state is refreshed while it's mounted
+type Inspector struct {
+	vecty.Core
+
+	ID string `vecty:"prop"` // actor to inspect
+
+	// Inspect returns the current state of the actor,
+	// ok is false if the actor is not on the board
+	Inspect     func(id string) (info Info, ok bool) `vecty:"prop"`
+	OnFollow    func(follow bool)                    `vecty:"prop"`
+	OnSpotlight func(spotlight bool)                 `vecty:"prop"`
+	OnClose     func()                               `vecty:"prop"`
+
+	mounted bool
+}
+
+// Mount implements the vecty.Mounter interface.
+func (i *Inspector) Mount() {
+	i.mounted = true
+	go i.update()
+}
+
+// Unmount implements the vecty.Unmounter interface.
+func (i *Inspector) Unmount() {
+	i.mounted = false
+}
+
+func (i *Inspector) update() {
+	for {
+		time.Sleep(refreshInterval)
+		if !i.mounted {
+			return
+		}
+		vecty.Rerender(i)
+	}
+}
+
+func row(title string, value vecty.MarkupOrChild) *vecty.HTML {
+	return elem.TableRow(
+		elem.TableHeader(vecty.Text(title)),
+		elem.TableData(value),
+	)
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func (i *Inspector) renderPen(info Info) vecty.MarkupOrChild {
+	if info.PenUp {
+		return vecty.Text("up")
+	}
+	return elem.Span(
+		elem.Span(
+			vecty.Markup(
+				vecty.Class("swatch"),
+				vecty.Style("background-color", info.Color),
+			),
+		),
+		vecty.Text(fmt.Sprintf("%s, width %s", info.Color, formatNumber(info.Width))),
+	)
+}
+
+func (i *Inspector) renderRecent(info Info) vecty.MarkupOrChild {
+	if len(info.Recent) == 0 {
+		return vecty.Text("—")
+	}
+
+	var items vecty.List
+	for _, cmd := range info.Recent {
+		items = append(items, elem.ListItem(vecty.Text(cmd)))
+	}
+	return elem.OrderedList(items)
+}
+
+func (i *Inspector) toggle(title, tooltip string, active bool, onChange func(bool)) *vecty.HTML {
+	return elem.Button(
+		vecty.Markup(
+			vecty.Property("title", tooltip),
+			vecty.MarkupIf(active, vecty.Class("active")),
+			event.Click(func(*vecty.Event) {
+				onChange(!active)
+				vecty.Rerender(i)
+			}),
+		),
+		vecty.Text(title),
+	)
+}
+
+// Render implements the vecty.Component interface.
+func (i *Inspector) Render() vecty.ComponentOrHTML {
+	info, ok := i.Inspect(i.ID)
+	if !ok {
+		return elem.Div()
+	}
+
+	return elem.Div(
+		vecty.Markup(
+			vecty.Class("inspector"),
+		),
+		elem.Div(
+			vecty.Markup(
+				vecty.Class("title"),
+			),
+			vecty.Text(info.Name),
+			elem.Button(
+				vecty.Markup(
+					vecty.Property("title", "Close"),
+					event.Click(func(*vecty.Event) { i.OnClose() }),
+				),
+				vecty.Text("×"),
+			),
+		),
+		elem.Table(
+			elem.TableBody(
+				row("ID", vecty.Text(info.ID)),
+				row("Position", vecty.Text(fmt.Sprintf("%.1f, %.1f", info.X, info.Y))),
+				row("Heading", vecty.Text(fmt.Sprintf("%.0f°", info.Heading))),
+				row("Pen", i.renderPen(info)),
+				row("Costume", vecty.Text(info.Costume)),
+				row("Queued", vecty.Text(strconv.Itoa(info.Queued))),
+				row("Recent", i.renderRecent(info)),
+			),
+		),
+		elem.Div(
+			vecty.Markup(
+				vecty.Class("actions"),
+			),
+			i.toggle("Follow", "Keep this gopher in the center of the board", info.Followed, i.OnFollow),
+			i.toggle("Spotlight", "Dim all the other artists", info.Spotlit, i.OnSpotlight),
+		),
+	)
+}
@@ -0,0 +1,107 @@
+package layers
+
+import (
+	"github.com/gopherjs/vecty"
+	"github.com/gopherjs/vecty/elem"
+	"github.com/gopherjs/vecty/event"
+)
+
+// Layer describes the drawing layer of a single artist
+type Layer struct {
+	ID      string
+	Name    string
+	Visible bool
+}
+
+// Layers implements the pane that allows to show and hide
+// the grid and artist layers, show a single artist (solo)
+// and clear an artist's drawing
+type Layers struct {
+	vecty.Core
+
+	Grid   bool    `vecty:"prop"` // grid visibility
+	Layers []Layer `vecty:"prop"` // in order of joining
+	Solo   string  `vecty:"prop"` // ID of the only artist shown, if any
+
+	OnGridChange    func(visible bool)            `vecty:"prop"`
+	OnVisibleChange func(id string, visible bool) `vecty:"prop"`
+	OnSoloChange    func(id string)               `vecty:"prop"`
+	OnClear         func(id string)               `vecty:"prop"`
+}
+
+func (l *Layers) renderLayer(layer Layer) *vecty.HTML {
+	id := layer.ID
+	solo := l.Solo == id
+
+	return elem.Div(
+		vecty.Markup(
+			vecty.Class("layer"),
+			vecty.MarkupIf(l.Solo != "" && !solo, vecty.Class("muted")),
+		),
+		elem.Label(
+			elem.Input(
+				vecty.Markup(
+					vecty.Property("type", "checkbox"),
+					vecty.Property("checked", layer.Visible),
+					event.Change(func(e *vecty.Event) {
+						l.OnVisibleChange(id, e.Get("target").Get("checked").Bool())
+					}),
+				),
+			),
+			vecty.Text(layer.Name),
+		),
+		elem.Button(
+			vecty.Markup(
+				vecty.Property("title", "Show only this artist"),
+				vecty.MarkupIf(solo, vecty.Class("active")),
+				event.Click(func(*vecty.Event) {
+					if solo {
+						l.OnSoloChange("")
+						return
+					}
+					l.OnSoloChange(id)
+				}),
+			),
+			vecty.Text("Solo"),
+		),
+		elem.Button(
+			vecty.Markup(
+				vecty.Property("title", "Erase this artist's drawing"),
+				event.Click(func(*vecty.Event) { l.OnClear(id) }),
+			),
+			vecty.Text("Clear"),
+		),
+	)
+}
+
+// Render implements the vecty.Component interface.
+func (l *Layers) Render() vecty.ComponentOrHTML {
+	var layers vecty.List
+	for _, layer := range l.Layers {
+		layers = append(layers, l.renderLayer(layer))
+	}
+
+	return elem.Div(
+		vecty.Markup(
+			vecty.Class("layers"),
+		),
+		elem.Div(
+			vecty.Markup(
+				vecty.Class("layer"),
+			),
+			elem.Label(
+				elem.Input(
+					vecty.Markup(
+						vecty.Property("type", "checkbox"),
+						vecty.Property("checked", l.Grid),
+						event.Change(func(e *vecty.Event) {
+							l.OnGridChange(e.Get("target").Get("checked").Bool())
+						}),
+					),
+				),
+				vecty.Text("Grid"),
+			),
+		),
+		layers,
+	)
+}
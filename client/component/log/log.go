@@ -0,0 +1,177 @@
+package log
+
+import (
+	"time"
+
+	"github.com/gopherjs/vecty"
+	"github.com/gopherjs/vecty/elem"
+	"github.com/gopherjs/vecty/event"
+	"github.com/iafan/goplayspace/client/js/document"
+	"github.com/iafan/goplayspace/client/util"
+)
+
+// Entry kinds
+const (
+	Join = iota
+	Action
+	ParseError
+	ConnError
+)
+
+// Entry represents a single line in the activity log
+type Entry struct {
+	Time     time.Time
+	ArtistID string
+	Kind     int
+	Text     string
+}
+
+// Log implements the activity log pane
+type Log struct {
+	vecty.Core
+
+	Entries []Entry           `vecty:"prop"`
+	Names   map[string]string `vecty:"prop"` // artist ID => name
+	Order   []string          `vecty:"prop"` // artist IDs in order of joining
+
+	// OnSelect is called when an entry related to an artist is clicked
+	OnSelect func(artistID string) `vecty:"prop"`
+
+	filter      string // artist ID to show entries for; empty means all
+	atBottom    bool
+	initialized bool
+}
+
+// ScrollToBottom scrolls the log to the most recent entry
+func (l *Log) ScrollToBottom() {
+	el := document.QuerySelector(".log")
+	if el == nil {
+		return
+	}
+	el.Set("scrollTop", el.Get("scrollHeight"))
+}
+
+// onScroll keeps track of whether the log is scrolled to the bottom,
+// in which case it will stick to the bottom as new entries arrive
+func (l *Log) onScroll(e *vecty.Event) {
+	el := e.Get("target")
+	l.atBottom = el.Get("scrollTop").Float()+el.Get("clientHeight").Float() >=
+		el.Get("scrollHeight").Float()-1
+}
+
+func (l *Log) onFilterChange(e *vecty.Event) {
+	l.filter = e.Get("target").Get("value").String()
+	vecty.Rerender(l)
+	util.Schedule(l.ScrollToBottom)
+}
+
+func (l *Log) name(id string) string {
+	if name := l.Names[id]; name != "" {
+		return name
+	}
+	return id
+}
+
+func (l *Log) renderFilter() *vecty.HTML {
+	options := vecty.List{
+		elem.Option(
+			vecty.Markup(
+				vecty.Property("value", ""),
+				vecty.Property("selected", l.filter == ""),
+			),
+			vecty.Text("All artists"),
+		),
+	}
+
+	for _, id := range l.Order {
+		options = append(options, elem.Option(
+			vecty.Markup(
+				vecty.Property("value", id),
+				vecty.Property("selected", l.filter == id),
+			),
+			vecty.Text(l.name(id)),
+		))
+	}
+
+	return elem.Div(
+		vecty.Markup(
+			vecty.Class("log-filter"),
+		),
+		elem.Select(
+			vecty.Markup(
+				event.Change(l.onFilterChange),
+			),
+			options,
+		),
+	)
+}
+
+func (l *Log) renderEntry(e Entry) *vecty.HTML {
+	var onClick vecty.Applyer
+	if e.ArtistID != "" && l.OnSelect != nil {
+		id := e.ArtistID
+		onClick = event.Click(func(*vecty.Event) { l.OnSelect(id) })
+	}
+
+	artist := "server"
+	if e.ArtistID != "" {
+		artist = l.name(e.ArtistID)
+	}
+
+	return elem.Div(
+		vecty.Markup(
+			vecty.Class("entry"),
+			vecty.MarkupIf(e.Kind == Join, vecty.Class("status")),
+			vecty.MarkupIf(e.Kind == ParseError || e.Kind == ConnError, vecty.Class("stderr")),
+			vecty.MarkupIf(onClick != nil, vecty.Class("clickable")),
+			onClick,
+		),
+		elem.Span(
+			vecty.Markup(
+				vecty.Class("time"),
+			),
+			vecty.Text(e.Time.Format("15:04:05")),
+		),
+		elem.Span(
+			vecty.Markup(
+				vecty.Class("artist"),
+			),
+			vecty.Text(artist),
+		),
+		vecty.Text(e.Text),
+	)
+}
+
+// Render implements the vecty.Component interface.
+func (l *Log) Render() vecty.ComponentOrHTML {
+	if !l.initialized {
+		l.initialized = true
+		l.atBottom = true
+	}
+
+	if l.atBottom {
+		util.Schedule(l.ScrollToBottom)
+	}
+
+	var entries vecty.List
+	for _, e := range l.Entries {
+		if l.filter != "" && e.ArtistID != l.filter {
+			continue
+		}
+		entries = append(entries, l.renderEntry(e))
+	}
+
+	return elem.Div(
+		vecty.Markup(
+			vecty.Class("log-pane"),
+		),
+		l.renderFilter(),
+		elem.Div(
+			vecty.Markup(
+				vecty.Class("log"),
+				event.Scroll(l.onScroll),
+			),
+			entries,
+		),
+	)
+}
@@ -0,0 +1,115 @@
+package settings
+
+import (
+	"strings"
+
+	"github.com/gopherjs/vecty"
+	"github.com/gopherjs/vecty/elem"
+	"github.com/gopherjs/vecty/event"
+	"github.com/iafan/goplayspace/client/prefs"
+	"github.com/iafan/goplayspace/client/theme"
+)
+
+// Settings implements the panel that allows to change
+// the viewer preferences
+type Settings struct {
+	vecty.Core
+
+	Prefs prefs.Prefs `vecty:"prop"`
+
+	OnChange func(p prefs.Prefs) `vecty:"prop"`
+	OnClose  func()              `vecty:"prop"`
+}
+
+func field(title string, input *vecty.HTML) *vecty.HTML {
+	return elem.Label(
+		vecty.Markup(
+			vecty.Class("field"),
+		),
+		elem.Span(vecty.Text(title)),
+		input,
+	)
+}
+
+// textInput returns the input that calls set with the trimmed value
+// once it has been changed
+func (s *Settings) textInput(kind, value string, set func(p *prefs.Prefs, value string)) *vecty.HTML {
+	return elem.Input(
+		vecty.Markup(
+			vecty.Property("type", kind),
+			vecty.Property("value", value),
+			event.Change(func(e *vecty.Event) {
+				p := s.Prefs
+				set(&p, strings.TrimSpace(e.Get("target").Get("value").String()))
+				s.OnChange(p)
+			}),
+		),
+	)
+}
+
+func (s *Settings) renderThemes() *vecty.HTML {
+	var themes vecty.List
+	for _, name := range theme.Names {
+		themes = append(themes, elem.Option(
+			vecty.Markup(
+				vecty.Property("value", name),
+				vecty.Property("selected", name == s.Prefs.Theme),
+			),
+			vecty.Text(name),
+		))
+	}
+
+	return elem.Select(
+		vecty.Markup(
+			event.Change(func(e *vecty.Event) {
+				p := s.Prefs
+				p.Theme = e.Get("target").Get("value").String()
+				s.OnChange(p)
+			}),
+		),
+		themes,
+	)
+}
+
+// Render implements the vecty.Component interface.
+func (s *Settings) Render() vecty.ComponentOrHTML {
+	return elem.Div(
+		vecty.Markup(
+			vecty.Class("settings-panel"),
+		),
+		elem.Div(
+			vecty.Markup(
+				vecty.Class("title"),
+			),
+			vecty.Text("Settings"),
+			elem.Button(
+				vecty.Markup(
+					vecty.Property("title", "Close"),
+					event.Click(func(*vecty.Event) { s.OnClose() }),
+				),
+				vecty.Text("×"),
+			),
+		),
+		field("Theme", s.renderThemes()),
+		field("Grid", elem.Input(
+			vecty.Markup(
+				vecty.Property("type", "checkbox"),
+				vecty.Property("checked", !s.Prefs.GridHidden),
+				event.Change(func(e *vecty.Event) {
+					p := s.Prefs
+					p.GridHidden = !e.Get("target").Get("checked").Bool()
+					s.OnChange(p)
+				}),
+			),
+		)),
+		field("Room", s.textInput("text", s.Prefs.Room, func(p *prefs.Prefs, v string) { p.Room = v })),
+		elem.Div(
+			vecty.Markup(
+				vecty.Class("group"),
+			),
+			vecty.Text("Student controller"),
+		),
+		field("Artist name", s.textInput("text", s.Prefs.ArtistName, func(p *prefs.Prefs, v string) { p.ArtistName = v })),
+		field("Token", s.textInput("password", s.Prefs.ArtistToken, func(p *prefs.Prefs, v string) { p.ArtistToken = v })),
+	)
+}
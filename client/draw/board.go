@@ -0,0 +1,121 @@
+package draw
+
+import "math"
+
+// BoardExtent is the number of steps visible in each direction
+// from the center of the board
+const BoardExtent = 15
+
+// Edge modes: what happens when the turtle reaches the edge of the board
+const (
+	EdgeNone   = "none"   // walks off the board
+	EdgeClamp  = "clamp"  // stops at the edge
+	EdgeWrap   = "wrap"   // comes back from the opposite edge
+	EdgeBounce = "bounce" // bounces off the edge
+)
+
+// maxEdgeCrossings limits the number of times a single move
+// can wrap around or bounce off the edges
+const maxEdgeCrossings = 100
+
+// Board is the area of the board in turtle coordinates
+// with the default edge mode
+type Board struct {
+	MinX, MinY float64
+	MaxX, MaxY float64
+	Edge       string
+}
+
+// NewBoard returns the board for a turtle starting
+// at the x, y point relative to the center of the board
+func NewBoard(x, y float64, edge string) *Board {
+	return &Board{
+		MinX: -BoardExtent - x, MinY: -BoardExtent - y,
+		MaxX: BoardExtent - x, MaxY: BoardExtent - y,
+		Edge: edge,
+	}
+}
+
+// exit returns the part (0..1) of the way from x1, y1 to x2, y2 at which
+// the line leaves the board (1 if it doesn't), and whether it crosses
+// a vertical (left or right) edge or a horizontal one
+func (b *Board) exit(x1, y1, x2, y2 float64) (k float64, vertical bool) {
+	k = 1
+	dx, dy := x2-x1, y2-y1
+
+	check := func(d, from, to, min, max float64, v bool) {
+		var edge float64
+		switch {
+		case d > 0 && to > max:
+			edge = max
+		case d < 0 && to < min:
+			edge = min
+		default:
+			return
+		}
+		if e := (edge - from) / d; e < k {
+			k, vertical = e, v
+		}
+	}
+	check(dx, x1, x2, b.MinX, b.MaxX, true)
+	check(dy, y1, y2, b.MinY, b.MaxY, false)
+
+	if k < 0 {
+		k = 0 // already off the board
+	}
+	return k, vertical
+}
+
+// arcExit returns the distance along the arc at which it leaves the board
+// (its length if it doesn't), whether it crosses a vertical edge
+// or a horizontal one, and the x (or y) of that edge
+func (b *Board) arcExit(s Segment) (d float64, vertical bool, edge float64) {
+	l := s.Length()
+	d = l
+	dir := 1.0
+	if s.Sweep < 0 {
+		dir = -1
+	}
+
+	// a is an angle at which the circle meets the edge, and v
+	// is the speed (per radian) at which the arc moves away from the board
+	check := func(a, v float64, ve bool, e float64) {
+		if v <= 1e-9*s.R {
+			return // moving back to the board, or just touching the edge
+		}
+		rel := math.Mod((a-s.A1)*dir, 2*math.Pi)
+		if rel < 0 {
+			rel += 2 * math.Pi
+		}
+		if rel > 2*math.Pi-1e-9 {
+			rel = 0 // at the start of the arc
+		}
+		if ad := rel * s.R; ad < d {
+			d, vertical, edge = ad, ve, e
+		}
+	}
+	for _, e := range []float64{b.MinX, b.MaxX} {
+		if k := (e - s.CX) / s.R; k >= -1 && k <= 1 {
+			out := 1.0
+			if e == b.MinX {
+				out = -1
+			}
+			for _, a := range []float64{math.Acos(k), -math.Acos(k)} {
+				check(a, -out*dir*s.R*math.Sin(a), true, e)
+			}
+		}
+	}
+	for _, e := range []float64{b.MinY, b.MaxY} {
+		if k := (e - s.CY) / s.R; k >= -1 && k <= 1 {
+			out := 1.0
+			if e == b.MinY {
+				out = -1
+			}
+			for _, a := range []float64{math.Asin(k), math.Pi - math.Asin(k)} {
+				check(a, out*dir*s.R*math.Cos(a), false, e)
+			}
+		}
+	}
+
+	return d, vertical, edge
+}
@@ -0,0 +1,380 @@
+package draw
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RandomColor picks a random color when used instead of a color
+const RandomColor = "random"
+
+var colorHexR = regexp.MustCompile(`^#([0-9a-f]{3}|[0-9a-f]{6})$`)
+var colorRGBR = regexp.MustCompile(`^rgb\(\s*(\d+)\s*,\s*(\d+)\s*,\s*(\d+)\s*\)$`)
+var colorHSLR = regexp.MustCompile(`^hsl\(\s*` + number + `(?:deg)?\s*,\s*` + positive + `%\s*,\s*` + positive + `%\s*\)$`)
+var colorShiftR = regexp.MustCompile(`^[+-]\d+(?:\.\d+)?$`)
+
+// gopherColors are the names of the gopher colors
+// that are not CSS color names (e.g. `color periwinkle`)
+var gopherColors = map[string]uint32{
+	"original":   0x6ad7e5,
+	"periwinkle": 0xccccff,
+	"fuschia":    0xff00ff,
+}
+
+// cssColors are the CSS color names
+var cssColors = map[string]uint32{
+	"aliceblue":            0xf0f8ff,
+	"antiquewhite":         0xfaebd7,
+	"aqua":                 0x00ffff,
+	"aquamarine":           0x7fffd4,
+	"azure":                0xf0ffff,
+	"beige":                0xf5f5dc,
+	"bisque":               0xffe4c4,
+	"black":                0x000000,
+	"blanchedalmond":       0xffebcd,
+	"blue":                 0x0000ff,
+	"blueviolet":           0x8a2be2,
+	"brown":                0xa52a2a,
+	"burlywood":            0xdeb887,
+	"cadetblue":            0x5f9ea0,
+	"chartreuse":           0x7fff00,
+	"chocolate":            0xd2691e,
+	"coral":                0xff7f50,
+	"cornflowerblue":       0x6495ed,
+	"cornsilk":             0xfff8dc,
+	"crimson":              0xdc143c,
+	"cyan":                 0x00ffff,
+	"darkblue":             0x00008b,
+	"darkcyan":             0x008b8b,
+	"darkgoldenrod":        0xb8860b,
+	"darkgray":             0xa9a9a9,
+	"darkgreen":            0x006400,
+	"darkgrey":             0xa9a9a9,
+	"darkkhaki":            0xbdb76b,
+	"darkmagenta":          0x8b008b,
+	"darkolivegreen":       0x556b2f,
+	"darkorange":           0xff8c00,
+	"darkorchid":           0x9932cc,
+	"darkred":              0x8b0000,
+	"darksalmon":           0xe9967a,
+	"darkseagreen":         0x8fbc8f,
+	"darkslateblue":        0x483d8b,
+	"darkslategray":        0x2f4f4f,
+	"darkslategrey":        0x2f4f4f,
+	"darkturquoise":        0x00ced1,
+	"darkviolet":           0x9400d3,
+	"deeppink":             0xff1493,
+	"deepskyblue":          0x00bfff,
+	"dimgray":              0x696969,
+	"dimgrey":              0x696969,
+	"dodgerblue":           0x1e90ff,
+	"firebrick":            0xb22222,
+	"floralwhite":          0xfffaf0,
+	"forestgreen":          0x228b22,
+	"fuchsia":              0xff00ff,
+	"gainsboro":            0xdcdcdc,
+	"ghostwhite":           0xf8f8ff,
+	"gold":                 0xffd700,
+	"goldenrod":            0xdaa520,
+	"gray":                 0x808080,
+	"green":                0x008000,
+	"greenyellow":          0xadff2f,
+	"grey":                 0x808080,
+	"honeydew":             0xf0fff0,
+	"hotpink":              0xff69b4,
+	"indianred":            0xcd5c5c,
+	"indigo":               0x4b0082,
+	"ivory":                0xfffff0,
+	"khaki":                0xf0e68c,
+	"lavender":             0xe6e6fa,
+	"lavenderblush":        0xfff0f5,
+	"lawngreen":            0x7cfc00,
+	"lemonchiffon":         0xfffacd,
+	"lightblue":            0xadd8e6,
+	"lightcoral":           0xf08080,
+	"lightcyan":            0xe0ffff,
+	"lightgoldenrodyellow": 0xfafad2,
+	"lightgray":            0xd3d3d3,
+	"lightgreen":           0x90ee90,
+	"lightgrey":            0xd3d3d3,
+	"lightpink":            0xffb6c1,
+	"lightsalmon":          0xffa07a,
+	"lightseagreen":        0x20b2aa,
+	"lightskyblue":         0x87cefa,
+	"lightslategray":       0x778899,
+	"lightslategrey":       0x778899,
+	"lightsteelblue":       0xb0c4de,
+	"lightyellow":          0xffffe0,
+	"lime":                 0x00ff00,
+	"limegreen":            0x32cd32,
+	"linen":                0xfaf0e6,
+	"magenta":              0xff00ff,
+	"maroon":               0x800000,
+	"mediumaquamarine":     0x66cdaa,
+	"mediumblue":           0x0000cd,
+	"mediumorchid":         0xba55d3,
+	"mediumpurple":         0x9370db,
+	"mediumseagreen":       0x3cb371,
+	"mediumslateblue":      0x7b68ee,
+	"mediumspringgreen":    0x00fa9a,
+	"mediumturquoise":      0x48d1cc,
+	"mediumvioletred":      0xc71585,
+	"midnightblue":         0x191970,
+	"mintcream":            0xf5fffa,
+	"mistyrose":            0xffe4e1,
+	"moccasin":             0xffe4b5,
+	"navajowhite":          0xffdead,
+	"navy":                 0x000080,
+	"oldlace":              0xfdf5e6,
+	"olive":                0x808000,
+	"olivedrab":            0x6b8e23,
+	"orange":               0xffa500,
+	"orangered":            0xff4500,
+	"orchid":               0xda70d6,
+	"palegoldenrod":        0xeee8aa,
+	"palegreen":            0x98fb98,
+	"paleturquoise":        0xafeeee,
+	"palevioletred":        0xdb7093,
+	"papayawhip":           0xffefd5,
+	"peachpuff":            0xffdab9,
+	"peru":                 0xcd853f,
+	"pink":                 0xffc0cb,
+	"plum":                 0xdda0dd,
+	"powderblue":           0xb0e0e6,
+	"purple":               0x800080,
+	"rebeccapurple":        0x663399,
+	"red":                  0xff0000,
+	"rosybrown":            0xbc8f8f,
+	"royalblue":            0x4169e1,
+	"saddlebrown":          0x8b4513,
+	"salmon":               0xfa8072,
+	"sandybrown":           0xf4a460,
+	"seagreen":             0x2e8b57,
+	"seashell":             0xfff5ee,
+	"sienna":               0xa0522d,
+	"silver":               0xc0c0c0,
+	"skyblue":              0x87ceeb,
+	"slateblue":            0x6a5acd,
+	"slategray":            0x708090,
+	"slategrey":            0x708090,
+	"snow":                 0xfffafa,
+	"springgreen":          0x00ff7f,
+	"steelblue":            0x4682b4,
+	"tan":                  0xd2b48c,
+	"teal":                 0x008080,
+	"thistle":              0xd8bfd8,
+	"tomato":               0xff6347,
+	"turquoise":            0x40e0d0,
+	"violet":               0xee82ee,
+	"wheat":                0xf5deb3,
+	"white":                0xffffff,
+	"whitesmoke":           0xf5f5f5,
+	"yellow":               0xffff00,
+	"yellowgreen":          0x9acd32,
+}
+
+// ParseColor parses a CSS color name (spaces and dashes are ignored,
+// so "light blue" is the same as "lightblue"), a gopher color name,
+// a #rgb or #rrggbb color, or an rgb(R, G, B) or hsl(H, S%, L%) color
+func ParseColor(s string) (color.RGBA, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	name := strings.NewReplacer(" ", "", "-", "", "_", "").Replace(s)
+	if n, ok := cssColors[name]; ok {
+		return rgb(n), nil
+	}
+	if n, ok := gopherColors[s]; ok {
+		return rgb(n), nil
+	}
+
+	if matches := colorHexR.FindStringSubmatch(s); matches != nil {
+		hex := matches[1]
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		n, _ := strconv.ParseUint(hex, 16, 32)
+		return rgb(uint32(n)), nil
+	}
+
+	if matches := colorRGBR.FindStringSubmatch(s); matches != nil {
+		var c [3]uint8
+		for i := range c {
+			n, err := strconv.ParseUint(matches[i+1], 10, 8)
+			if err != nil {
+				return color.RGBA{}, fmt.Errorf("rgb values must be between 0 and 255")
+			}
+			c[i] = uint8(n)
+		}
+		return color.RGBA{c[0], c[1], c[2], 0xff}, nil
+	}
+
+	if matches := colorHSLR.FindStringSubmatch(s); matches != nil {
+		h, _ := strconv.ParseFloat(matches[1], 64)
+		sat, _ := strconv.ParseFloat(matches[2], 64)
+		l, _ := strconv.ParseFloat(matches[3], 64)
+		if sat > 100 || l > 100 {
+			return color.RGBA{}, fmt.Errorf("saturation and lightness must be between 0%% and 100%%")
+		}
+		return hsl(h, sat/100, l/100), nil
+	}
+
+	return color.RGBA{}, fmt.Errorf("unknown color")
+}
+
+// CSSColor formats the color as #rrggbb
+func CSSColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// checkColor checks the color given in a command: any color accepted
+// by ParseColor, `random`, or a hue shift in degrees like +30 or -30
+func checkColor(s string) error {
+	if s == RandomColor || colorShiftR.MatchString(s) {
+		return nil
+	}
+	_, err := ParseColor(s)
+	return err
+}
+
+// splitColors splits the space-separated list of colors,
+// keeping the spaces inside parentheses (e.g. in rgb(1, 2, 3))
+func splitColors(s string) []string {
+	var out []string
+	depth, start := 0, -1
+	for i, r := range s + " " {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == ' ' && depth <= 0:
+			if start >= 0 {
+				out = append(out, s[start:i])
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	return out
+}
+
+// resolveColor returns the CSS color for the color given in a command;
+// hue shifts are relative to the current color
+func resolveColor(s, current string) string {
+	if s == RandomColor {
+		return CSSColor(hsl(rand.Float64()*360, 0.5+rand.Float64()*0.5, 0.3+rand.Float64()*0.4))
+	}
+
+	if colorShiftR.MatchString(s) {
+		shift, _ := strconv.ParseFloat(s, 64)
+		c, err := ParseColor(current)
+		if err != nil {
+			c, _ = ParseColor(DefaultColor)
+		}
+		h, sat, l := toHSL(c)
+		return CSSColor(hsl(h+shift, sat, l))
+	}
+
+	c, err := ParseColor(s)
+	if err != nil {
+		return s
+	}
+	return CSSColor(c)
+}
+
+// ResolveRandomColors replaces `random` colors in the command with
+// random colors, so that everyone watching the board sees the same ones
+func ResolveRandomColors(cmd string) string {
+	a, err := ParseCommand(cmd, 0)
+	if err != nil {
+		return cmd
+	}
+	switch a.Kind {
+	case Color, FillColor, Gradient:
+	default:
+		return cmd
+	}
+
+	fields := strings.Fields(a.Cmd)
+	replaced := false
+	for i := 1; i < len(fields); i++ {
+		if fields[i] == RandomColor {
+			fields[i] = resolveColor(RandomColor, "")
+			replaced = true
+		}
+	}
+	if !replaced {
+		return cmd
+	}
+	return strings.Join(fields, " ")
+}
+
+func rgb(n uint32) color.RGBA {
+	return color.RGBA{uint8(n >> 16), uint8(n >> 8), uint8(n), 0xff}
+}
+
+// hsl converts the hue (in degrees), saturation and lightness (0..1) to RGB
+func hsl(h, s, l float64) color.RGBA {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - c/2
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+
+	v := func(f float64) uint8 {
+		return uint8(math.Round((f + m) * 255))
+	}
+	return color.RGBA{v(r), v(g), v(b), 0xff}
+}
+
+// toHSL converts the color to the hue (in degrees),
+// saturation and lightness (0..1)
+func toHSL(c color.RGBA) (h, s, l float64) {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	l = (max + min) / 2
+
+	d := max - min
+	if d == 0 {
+		return 0, 0, l
+	}
+	s = d / (1 - math.Abs(2*l-1))
+
+	switch max {
+	case r:
+		h = 60 * math.Mod((g-b)/d, 6)
+	case g:
+		h = 60 * ((b-r)/d + 2)
+	default:
+		h = 60 * ((r-g)/d + 4)
+	}
+	return h, s, l
+}
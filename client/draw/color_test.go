@@ -0,0 +1,134 @@
+package draw
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		s    string
+		want color.RGBA
+	}{
+		{"red", color.RGBA{0xff, 0, 0, 0xff}},
+		{"  RED ", color.RGBA{0xff, 0, 0, 0xff}},
+		{"light blue", color.RGBA{0xad, 0xd8, 0xe6, 0xff}},
+		{"light-blue", color.RGBA{0xad, 0xd8, 0xe6, 0xff}},
+		{"light_blue", color.RGBA{0xad, 0xd8, 0xe6, 0xff}},
+		{"original", color.RGBA{0x6a, 0xd7, 0xe5, 0xff}},
+		{"periwinkle", color.RGBA{0xcc, 0xcc, 0xff, 0xff}},
+		{"#f80", color.RGBA{0xff, 0x88, 0x00, 0xff}},
+		{"#12AB9f", color.RGBA{0x12, 0xab, 0x9f, 0xff}},
+		{"rgb(1, 2, 3)", color.RGBA{1, 2, 3, 0xff}},
+		{"rgb(255,255,255)", color.RGBA{0xff, 0xff, 0xff, 0xff}},
+		{"hsl(0, 100%, 50%)", color.RGBA{0xff, 0, 0, 0xff}},
+		{"hsl(120deg, 100%, 25%)", color.RGBA{0, 0x80, 0, 0xff}},
+		{"hsl(-120, 100%, 50%)", color.RGBA{0, 0, 0xff, 0xff}},
+		{"hsl(0, 0%, 100%)", color.RGBA{0xff, 0xff, 0xff, 0xff}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseColor(tt.s)
+		if err != nil {
+			t.Errorf("ParseColor(%q): %v", tt.s, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseColor(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestParseColorErrors(t *testing.T) {
+	tests := []struct {
+		s   string
+		msg string
+	}{
+		{"", "unknown color"},
+		{"nosuchcolor", "unknown color"},
+		{"#ff", "unknown color"},
+		{"#ggg", "unknown color"},
+		{"rgb(1, 2)", "unknown color"},
+		{"rgb(256, 0, 0)", "rgb values must be between 0 and 255"},
+		{"hsl(0, 101%, 50%)", "saturation and lightness must be between 0% and 100%"},
+		{"hsl(0, 50%, 150%)", "saturation and lightness must be between 0% and 100%"},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseColor(tt.s)
+		if err == nil || err.Error() != tt.msg {
+			t.Errorf("ParseColor(%q) error = %v, want %q", tt.s, err, tt.msg)
+		}
+	}
+}
+
+func TestResolveColor(t *testing.T) {
+	tests := []struct {
+		s, current string
+		want       string
+	}{
+		{"red", "", "#ff0000"},
+		{"rgb(0, 128, 0)", "blue", "#008000"},
+		{"+120", "red", "#00ff00"},
+		{"-120", "red", "#0000ff"},
+		{"+360", "#336699", "#336699"},
+		{"+90", "", "#000000"}, // shifts the default color if the pen is off
+	}
+
+	for _, tt := range tests {
+		if got := resolveColor(tt.s, tt.current); got != tt.want {
+			t.Errorf("resolveColor(%q, %q) = %q, want %q", tt.s, tt.current, got, tt.want)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		got := resolveColor(RandomColor, "")
+		if _, err := ParseColor(got); err != nil {
+			t.Errorf("resolveColor(%q) = %q: %v", RandomColor, got, err)
+		}
+	}
+}
+
+func TestSplitColors(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"red", []string{"red"}},
+		{"red  blue", []string{"red", "blue"}},
+		{"rgb(1, 2, 3) hsl(0, 0%, 0%)", []string{"rgb(1, 2, 3)", "hsl(0, 0%, 0%)"}},
+	}
+
+	for _, tt := range tests {
+		got := splitColors(tt.s)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("splitColors(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestResolveRandomColors(t *testing.T) {
+	// commands without random colors are kept as is
+	for _, cmd := range []string{"forward 3", "color red", "say random", "gradient red blue", "jump random"} {
+		if got := ResolveRandomColors(cmd); got != cmd {
+			t.Errorf("ResolveRandomColors(%q) = %q", cmd, got)
+		}
+	}
+
+	for _, cmd := range []string{"color random", "fill random", "gradient random red", "gradient red random"} {
+		got := ResolveRandomColors(cmd)
+		if strings.Contains(got, RandomColor) {
+			t.Errorf("ResolveRandomColors(%q) = %q, want the random color resolved", cmd, got)
+			continue
+		}
+		a, err := ParseCommand(got, 1)
+		if err != nil {
+			t.Errorf("ResolveRandomColors(%q) = %q: %v", cmd, got, err)
+			continue
+		}
+		if a.Kind != Color && a.Kind != FillColor && a.Kind != Gradient {
+			t.Errorf("ResolveRandomColors(%q) = %q, which is not a color command", cmd, got)
+		}
+	}
+}
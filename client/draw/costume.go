@@ -0,0 +1,60 @@
+package draw
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// DefaultCostume is the name of the costume artists start with
+const DefaultCostume = "gopher"
+
+// Costume describes a sprite sheet with the frames of the walk animation
+// laid out horizontally, the character facing up. The middle frame
+// is shown when the character stands or turns.
+type Costume struct {
+	Name   string
+	Image  string // sprite sheet URL
+	Frames int
+
+	// frame size in px
+	Width  float64
+	Height float64
+
+	// the point the character rotates around,
+	// in px from the top left corner of the frame
+	PivotX float64
+	PivotY float64
+}
+
+// Costumes is the costume registry by name
+type Costumes map[string]*Costume
+
+// ReadCostumes reads the costume registry (a JSON list of costumes)
+func ReadCostumes(r io.Reader) (Costumes, error) {
+	var list []*Costume
+	if err := json.NewDecoder(r).Decode(&list); err != nil {
+		return nil, fmt.Errorf("error decoding costumes: %v", err)
+	}
+
+	costumes := make(Costumes, len(list))
+	for _, c := range list {
+		if c.Frames < 1 || c.Width <= 0 || c.Height <= 0 {
+			return nil, fmt.Errorf("costume %q: frames and size must be positive", c.Name)
+		}
+		costumes[c.Name] = c
+	}
+	return costumes, nil
+}
+
+// Check returns an error if the command sets a costume
+// missing from the registry
+func (c Costumes) Check(a *Action) error {
+	if a.Kind != SetCostume {
+		return nil
+	}
+	if _, ok := c[a.SVal]; !ok {
+		return &ParseError{a.Line, a.Cmd, "unknown costume"}
+	}
+	return nil
+}
@@ -2,13 +2,21 @@ package draw
 
 import (
 	"fmt"
+	"math"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const cmdStartDrawMode = "draw mode"
 
+// number is a (possibly negative) integer or decimal number
+const number = `(-?\d+(?:\.\d+)?)`
+
+// positive is a non-negative integer or decimal number
+const positive = `(\d+(?:\.\d+)?)`
+
 var cmdForwardR = regexp.MustCompile(`^forward$`)
 var cmdForwardNR = regexp.MustCompile(`^forward (\d+(\.\d+)?)$`)
 var cmdLeftR = regexp.MustCompile(`^left$`)
@@ -19,7 +27,41 @@ var cmdColorOffR = regexp.MustCompile(`^(?:color|colour) off$`)
 var cmdColorSR = regexp.MustCompile(`^(?:color|colour) (.+)$`)
 var cmdWidthNR = regexp.MustCompile(`^width (\d+(\.\d+)?)$`)
 var cmdSaySR = regexp.MustCompile(`^say (.+)$`)
-
+var cmdGotoNNR = regexp.MustCompile(`^goto ` + number + ` ` + number + `$`)
+var cmdHomeR = regexp.MustCompile(`^home$`)
+var cmdSetHeadingNR = regexp.MustCompile(`^setheading ` + number + `$`)
+var cmdSetXNR = regexp.MustCompile(`^setx ` + number + `$`)
+var cmdSetYNR = regexp.MustCompile(`^sety ` + number + `$`)
+var cmdPenUpR = regexp.MustCompile(`^penup$`)
+var cmdPenDownR = regexp.MustCompile(`^pendown$`)
+var cmdCircleNR = regexp.MustCompile(`^circle ` + positive + `$`)
+var cmdArcNNR = regexp.MustCompile(`^arc ` + number + ` ` + positive + `$`)
+var cmdDotR = regexp.MustCompile(`^dot$`)
+var cmdDotNR = regexp.MustCompile(`^dot ` + positive + `$`)
+var cmdFillSR = regexp.MustCompile(`^fill (.+)$`)
+var cmdBeginFillR = regexp.MustCompile(`^begin_?fill$`)
+var cmdBeginFillSR = regexp.MustCompile(`^begin_?fill (nonzero|evenodd)$`)
+var cmdEndFillR = regexp.MustCompile(`^end_?fill$`)
+var cmdPolygonNNR = regexp.MustCompile(`^polygon (\d+) ` + positive + `$`)
+var cmdDashOffR = regexp.MustCompile(`^dash off$`)
+var cmdDashNR = regexp.MustCompile(`^dash ` + positive + `$`)
+var cmdDashNNR = regexp.MustCompile(`^dash ` + positive + ` ` + positive + `$`)
+var cmdOpacityNR = regexp.MustCompile(`^opacity ` + positive + `$`)
+var cmdCapSR = regexp.MustCompile(`^cap (butt|round|square)$`)
+var cmdGradientOffR = regexp.MustCompile(`^gradient off$`)
+var cmdGradientSR = regexp.MustCompile(`^gradient (.+)$`)
+var cmdWaitNR = regexp.MustCompile(`^wait ` + positive + `$`)
+var cmdSpeedNR = regexp.MustCompile(`^speed (\d+)$`)
+var cmdSpeedInstantR = regexp.MustCompile(`^speed instant$`)
+var cmdEdgeSR = regexp.MustCompile(`^edge (none|clamp|wrap|bounce)$`)
+var cmdCostumeSR = regexp.MustCompile(`^costume (\S+)$`)
+
+// Action kinds. Absolute positions (Goto, SetX, SetY) are in steps
+// relative to the starting point of the actor, with the Y axis pointing up;
+// headings (SetHeading) are in degrees clockwise, with 0 pointing up.
+// Shapes (Circle, Arc, Polygon) are traced turning right.
+// Colors are anything ParseColor accepts, `random`, or a hue shift
+// relative to the current color in degrees (e.g. +30).
 const (
 	Step = iota
 	Left
@@ -27,17 +69,99 @@ const (
 	Color
 	Width
 	Say
+	Goto       // FVal, FVal2: x, y
+	Home       // back to the starting point, heading up
+	SetHeading // FVal: heading
+	SetX       // FVal: x
+	SetY       // FVal: y
+	PenUp
+	PenDown
+	Circle  // FVal: radius
+	Arc     // FVal: angle (negative to turn left), FVal2: radius
+	Dot     // FVal: diameter in px (0 for the default size)
+	Polygon // FVal: number of sides, FVal2: side length
+
+	FillColor // SVal: color
+	BeginFill // SVal: fill rule
+	EndFill
+
+	Dash     // FVal, FVal2: dash and gap length in px (0 for solid lines)
+	Opacity  // FVal: 0..1
+	Cap      // SVal: line cap
+	Gradient // SVal, SVal2: start and end colors (empty to turn off)
+
+	Wait  // FVal: seconds
+	Speed // FVal: 1 (slowest) to 10 (fastest), 0 for instant
+	Edge  // SVal: edge mode
+
+	SetCostume // SVal: costume name
+)
+
+// Seconds returns the action duration of s seconds, limited to MaxDuration
+// (durations set along with commands are not checked by ParseCommand)
+func Seconds(s float64) time.Duration {
+	if s >= MaxDuration.Seconds() {
+		return MaxDuration
+	}
+	return time.Duration(s * float64(time.Second))
+}
+
+// MaxSpeed is the fastest actor speed other than instant
+const MaxSpeed = 10
+
+// Limits of the command arguments, so that a single command
+// can't make the board (or a time-lapse) draw forever
+const (
+	MaxDistance = 1000 // steps, for moves, positions and shape sizes
+	MaxSides    = 360  // polygon sides
+	MaxArc      = 360  // arc angle in degrees, either way
+	MaxTurn     = 360  // left and right turns in degrees
+	MaxWidth    = 100  // px, for the pen width and dot sizes
+
+	// MaxDuration is the longest an action can take, either
+	// with `wait` or with a duration set along with a command
+	MaxDuration = time.Minute
+)
+
+// Fill rules
+const (
+	NonZero = "nonzero"
+	EvenOdd = "evenodd"
 )
 
 type Action struct {
+	Cmd   string
+	Kind  int
+	FVal  float64
+	FVal2 float64
+	SVal  string
+	SVal2 string
+
+	// Duration (if not zero) is how long the action takes,
+	// regardless of the actor speed
+	Duration time.Duration
+
+	// Line is the 1-based source line number the action was parsed from
+	Line int
+}
+
+// ParseError is reported for commands that can't be parsed
+type ParseError struct {
+	Line int
 	Cmd  string
-	Kind int
-	FVal float64
-	SVal string
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %q", e.Msg, e.Cmd)
+	}
+	return fmt.Sprintf("line %d: %s: %q", e.Line, e.Msg, e.Cmd)
 }
 
 type Actor interface {
 	ID() string
+	Name() string
 	Next() (*Action, bool)
 }
 
@@ -45,11 +169,20 @@ type ActorsList interface {
 	Actors() []Actor
 }
 
-func New(instructions []string) ActorsList {
+// Queuer is implemented by actors that know how many actions
+// they have received but not returned from Next yet
+type Queuer interface {
+	Queued() int
+}
+
+// New returns the actors drawing the instructions that follow
+// the "draw mode" line; onError (if not nil) is called for the lines
+// that can't be parsed, which are skipped
+func New(instructions []string, onError func(id string, err error)) ActorsList {
 	var actors []Actor
 
 	for i, s := range instructions {
-		actor := parseString(strconv.Itoa(i), s)
+		actor := parseString(strconv.Itoa(i), s, onError)
 		converted := Actor(actor)
 
 		actors = append(actors, converted)
@@ -102,85 +235,335 @@ func (s *SimpleActor) Next() (*Action, bool) {
 	return nextActions, true
 }
 
+func (s *SimpleActor) Queued() int {
+	return len(s.actions) - s.currentIndex
+}
+
 func (s *SimpleActor) ID() string {
 	return s.id
 }
 
-func parseLines(id string, lines []string) *SimpleActor {
+func (s *SimpleActor) Name() string {
+	return "Actor " + s.id
+}
+
+func parseLines(id string, lines []string, onError func(id string, err error)) *SimpleActor {
 	var a []*Action
 
 	isDrawMode := false
 
-	for _, line := range lines {
+	for i, line := range lines {
 		line = strings.ToLower(strings.TrimSpace(line))
+		lineNo := i + 1
 
-		if !isDrawMode && line != cmdStartDrawMode {
+		if !isDrawMode {
+			isDrawMode = line == cmdStartDrawMode
 			continue
 		}
-		isDrawMode = true
-
-		if matches := cmdForwardR.FindAllStringSubmatch(line, -1); matches != nil {
-			a = append(a, &Action{line, Step, 1, ""})
+		if line == "" {
 			continue
 		}
 
-		if matches := cmdForwardNR.FindAllStringSubmatch(line, -1); matches != nil {
-			n, _ := strconv.ParseFloat(matches[0][1], 64)
-			a = append(a, &Action{line, Step, n, ""})
+		action, err := ParseCommand(line, lineNo)
+		if err != nil {
+			reportError(onError, id, err)
 			continue
 		}
+		a = append(a, action)
+	}
 
-		if matches := cmdLeftR.FindAllStringSubmatch(line, -1); matches != nil {
-			a = append(a, &Action{line, Left, 90, ""})
-			continue
+	if !isDrawMode {
+		reportError(onError, id, &ParseError{Msg: "missing the start line", Cmd: cmdStartDrawMode})
+	}
+
+	return &SimpleActor{
+		id:      id,
+		actions: a,
+	}
+}
+
+// ParseCommand parses a single command (e.g. a move description
+// received from the server); lineNo is stored in the resulting action
+func ParseCommand(line string, lineNo int) (*Action, error) {
+	line = strings.ToLower(strings.TrimSpace(line))
+	a := &Action{Cmd: line, Line: lineNo}
+
+	num := func(s string) float64 {
+		n, _ := strconv.ParseFloat(s, 64)
+		return n
+	}
+
+	// within returns a ParseError if any of the values is over the limit
+	within := func(limit float64, what string, vals ...float64) error {
+		for _, v := range vals {
+			if math.Abs(v) > limit {
+				return &ParseError{lineNo, line, fmt.Sprintf("%s must be at most %v", what, limit)}
+			}
 		}
+		return nil
+	}
+	const distance = "distance"
 
-		if matches := cmdLeftNR.FindAllStringSubmatch(line, -1); matches != nil {
-			n, _ := strconv.ParseFloat(matches[0][1], 64)
-			a = append(a, &Action{line, Left, n, ""})
-			continue
+	if matches := cmdForwardR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.FVal = Step, 1
+		return a, nil
+	}
+
+	if matches := cmdForwardNR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.FVal = Step, num(matches[0][1])
+		if err := within(MaxDistance, distance, a.FVal); err != nil {
+			return nil, err
 		}
+		return a, nil
+	}
 
-		if matches := cmdRightR.FindAllStringSubmatch(line, -1); matches != nil {
-			a = append(a, &Action{line, Right, 90, ""})
-			continue
+	if matches := cmdLeftR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.FVal = Left, 90
+		return a, nil
+	}
+
+	if matches := cmdLeftNR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.FVal = Left, num(matches[0][1])
+		if err := within(MaxTurn, "turn angle", a.FVal); err != nil {
+			return nil, err
 		}
+		return a, nil
+	}
 
-		if matches := cmdRightNR.FindAllStringSubmatch(line, -1); matches != nil {
-			n, _ := strconv.ParseFloat(matches[0][1], 64)
-			a = append(a, &Action{line, Right, n, ""})
-			continue
+	if matches := cmdRightR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.FVal = Right, 90
+		return a, nil
+	}
+
+	if matches := cmdRightNR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.FVal = Right, num(matches[0][1])
+		if err := within(MaxTurn, "turn angle", a.FVal); err != nil {
+			return nil, err
 		}
+		return a, nil
+	}
 
-		if matches := cmdColorOffR.FindAllStringSubmatch(line, -1); matches != nil {
-			a = append(a, &Action{line, Color, 0, ""})
-			continue
+	if matches := cmdColorOffR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind = Color
+		return a, nil
+	}
+
+	if matches := cmdColorSR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.SVal = Color, matches[0][1]
+		if err := checkColor(a.SVal); err != nil {
+			return nil, &ParseError{lineNo, line, err.Error()}
 		}
+		return a, nil
+	}
 
-		if matches := cmdColorSR.FindAllStringSubmatch(line, -1); matches != nil {
-			a = append(a, &Action{line, Color, 0, matches[0][1]})
-			continue
+	if matches := cmdWidthNR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.FVal = Width, num(matches[0][1])
+		if err := within(MaxWidth, "width", a.FVal); err != nil {
+			return nil, err
 		}
+		return a, nil
+	}
 
-		if matches := cmdWidthNR.FindAllStringSubmatch(line, -1); matches != nil {
-			n, _ := strconv.ParseFloat(matches[0][1], 64)
-			a = append(a, &Action{line, Width, n, ""})
-			continue
+	if matches := cmdSaySR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.SVal = Say, matches[0][1]
+		return a, nil
+	}
+
+	if matches := cmdGotoNNR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.FVal, a.FVal2 = Goto, num(matches[0][1]), num(matches[0][2])
+		if err := within(MaxDistance, distance, a.FVal, a.FVal2); err != nil {
+			return nil, err
 		}
+		return a, nil
+	}
 
-		if matches := cmdSaySR.FindAllStringSubmatch(line, -1); matches != nil {
-			a = append(a, &Action{line, Say, 0, matches[0][1]})
-			continue
+	if matches := cmdHomeR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind = Home
+		return a, nil
+	}
+
+	if matches := cmdSetHeadingNR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.FVal = SetHeading, num(matches[0][1])
+		return a, nil
+	}
+
+	if matches := cmdSetXNR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.FVal = SetX, num(matches[0][1])
+		if err := within(MaxDistance, distance, a.FVal); err != nil {
+			return nil, err
 		}
+		return a, nil
+	}
 
+	if matches := cmdSetYNR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.FVal = SetY, num(matches[0][1])
+		if err := within(MaxDistance, distance, a.FVal); err != nil {
+			return nil, err
+		}
+		return a, nil
 	}
 
-	return &SimpleActor{
-		id:      id,
-		actions: a,
+	if matches := cmdPenUpR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind = PenUp
+		return a, nil
+	}
+
+	if matches := cmdPenDownR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind = PenDown
+		return a, nil
+	}
+
+	if matches := cmdCircleNR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.FVal = Circle, num(matches[0][1])
+		if err := within(MaxDistance, "radius", a.FVal); err != nil {
+			return nil, err
+		}
+		return a, nil
+	}
+
+	if matches := cmdArcNNR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.FVal, a.FVal2 = Arc, num(matches[0][1]), num(matches[0][2])
+		if err := within(MaxArc, "arc angle", a.FVal); err != nil {
+			return nil, err
+		}
+		if err := within(MaxDistance, "radius", a.FVal2); err != nil {
+			return nil, err
+		}
+		return a, nil
+	}
+
+	if matches := cmdDotR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind = Dot
+		return a, nil
+	}
+
+	if matches := cmdDotNR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.FVal = Dot, num(matches[0][1])
+		if err := within(MaxWidth, "dot size", a.FVal); err != nil {
+			return nil, err
+		}
+		return a, nil
+	}
+
+	if matches := cmdPolygonNNR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.FVal, a.FVal2 = Polygon, num(matches[0][1]), num(matches[0][2])
+		if a.FVal < 3 {
+			return nil, &ParseError{lineNo, line, "a polygon needs at least 3 sides"}
+		}
+		if err := within(MaxSides, "number of sides", a.FVal); err != nil {
+			return nil, err
+		}
+		if err := within(MaxDistance, "side length", a.FVal2); err != nil {
+			return nil, err
+		}
+		return a, nil
+	}
+
+	if matches := cmdFillSR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.SVal = FillColor, matches[0][1]
+		if err := checkColor(a.SVal); err != nil {
+			return nil, &ParseError{lineNo, line, err.Error()}
+		}
+		return a, nil
+	}
+
+	if matches := cmdBeginFillR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.SVal = BeginFill, NonZero
+		return a, nil
+	}
+
+	if matches := cmdBeginFillSR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.SVal = BeginFill, matches[0][1]
+		return a, nil
 	}
+
+	if matches := cmdEndFillR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind = EndFill
+		return a, nil
+	}
+
+	if matches := cmdDashOffR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind = Dash
+		return a, nil
+	}
+
+	if matches := cmdDashNR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.FVal, a.FVal2 = Dash, num(matches[0][1]), num(matches[0][1])
+		return a, nil
+	}
+
+	if matches := cmdDashNNR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.FVal, a.FVal2 = Dash, num(matches[0][1]), num(matches[0][2])
+		return a, nil
+	}
+
+	if matches := cmdOpacityNR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.FVal = Opacity, num(matches[0][1])
+		if a.FVal > 1 {
+			return nil, &ParseError{lineNo, line, "opacity must be between 0 and 1"}
+		}
+		return a, nil
+	}
+
+	if matches := cmdCapSR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.SVal = Cap, matches[0][1]
+		return a, nil
+	}
+
+	if matches := cmdGradientOffR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind = Gradient
+		return a, nil
+	}
+
+	if matches := cmdGradientSR.FindAllStringSubmatch(line, -1); matches != nil {
+		colors := splitColors(matches[0][1])
+		if len(colors) != 2 {
+			return nil, &ParseError{lineNo, line, "a gradient needs two colors"}
+		}
+		for _, c := range colors {
+			if err := checkColor(c); err != nil {
+				return nil, &ParseError{lineNo, line, err.Error()}
+			}
+		}
+		a.Kind, a.SVal, a.SVal2 = Gradient, colors[0], colors[1]
+		return a, nil
+	}
+
+	if matches := cmdWaitNR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.FVal = Wait, num(matches[0][1])
+		if err := within(MaxDuration.Seconds(), "wait", a.FVal); err != nil {
+			return nil, err
+		}
+		a.Duration = time.Duration(a.FVal * float64(time.Second))
+		return a, nil
+	}
+
+	if matches := cmdSpeedNR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.FVal = Speed, num(matches[0][1])
+		if a.FVal < 1 || a.FVal > MaxSpeed {
+			return nil, &ParseError{lineNo, line, "speed must be from 1 to 10, or instant"}
+		}
+		return a, nil
+	}
+
+	if matches := cmdSpeedInstantR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind = Speed
+		return a, nil
+	}
+
+	if matches := cmdEdgeSR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.SVal = Edge, matches[0][1]
+		return a, nil
+	}
+
+	if matches := cmdCostumeSR.FindAllStringSubmatch(line, -1); matches != nil {
+		a.Kind, a.SVal = SetCostume, matches[0][1]
+		return a, nil
+	}
+
+	return nil, &ParseError{lineNo, line, "unknown command"}
 }
 
-func parseString(id string, s string) *SimpleActor {
-	return parseLines(id, strings.Split(s, "\n"))
+func parseString(id string, s string, onError func(id string, err error)) *SimpleActor {
+	return parseLines(id, strings.Split(s, "\n"), onError)
 }
@@ -0,0 +1,198 @@
+package draw
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want Action
+	}{
+		{"forward", Action{Kind: Step, FVal: 1}},
+		{"forward 2.5", Action{Kind: Step, FVal: 2.5}},
+		{"  Forward 3 ", Action{Cmd: "forward 3", Kind: Step, FVal: 3}},
+		{"left", Action{Kind: Left, FVal: 90}},
+		{"left 30", Action{Kind: Left, FVal: 30}},
+		{"right", Action{Kind: Right, FVal: 90}},
+		{"right 45.5", Action{Kind: Right, FVal: 45.5}},
+		{"color off", Action{Kind: Color}},
+		{"colour red", Action{Kind: Color, SVal: "red"}},
+		{"color light blue", Action{Kind: Color, SVal: "light blue"}},
+		{"color random", Action{Kind: Color, SVal: RandomColor}},
+		{"color +30", Action{Kind: Color, SVal: "+30"}},
+		{"width 4", Action{Kind: Width, FVal: 4}},
+		{"say hello there", Action{Kind: Say, SVal: "hello there"}},
+		{"goto -3 4.5", Action{Kind: Goto, FVal: -3, FVal2: 4.5}},
+		{"home", Action{Kind: Home}},
+		{"setheading -90", Action{Kind: SetHeading, FVal: -90}},
+		{"setx 2", Action{Kind: SetX, FVal: 2}},
+		{"sety -2", Action{Kind: SetY, FVal: -2}},
+		{"penup", Action{Kind: PenUp}},
+		{"pendown", Action{Kind: PenDown}},
+		{"circle 3", Action{Kind: Circle, FVal: 3}},
+		{"arc -90 2", Action{Kind: Arc, FVal: -90, FVal2: 2}},
+		{"dot", Action{Kind: Dot}},
+		{"dot 10", Action{Kind: Dot, FVal: 10}},
+		{"polygon 6 2", Action{Kind: Polygon, FVal: 6, FVal2: 2}},
+		{"fill #f00", Action{Kind: FillColor, SVal: "#f00"}},
+		{"beginfill", Action{Kind: BeginFill, SVal: NonZero}},
+		{"begin_fill evenodd", Action{Kind: BeginFill, SVal: EvenOdd}},
+		{"endfill", Action{Kind: EndFill}},
+		{"end_fill", Action{Kind: EndFill}},
+		{"dash off", Action{Kind: Dash}},
+		{"dash 4", Action{Kind: Dash, FVal: 4, FVal2: 4}},
+		{"dash 4 2", Action{Kind: Dash, FVal: 4, FVal2: 2}},
+		{"opacity 0.5", Action{Kind: Opacity, FVal: 0.5}},
+		{"cap round", Action{Kind: Cap, SVal: "round"}},
+		{"gradient off", Action{Kind: Gradient}},
+		{"gradient red blue", Action{Kind: Gradient, SVal: "red", SVal2: "blue"}},
+		{"gradient rgb(1, 2, 3) #fff", Action{Kind: Gradient, SVal: "rgb(1, 2, 3)", SVal2: "#fff"}},
+		{"wait 1.5", Action{Kind: Wait, FVal: 1.5, Duration: 1500 * time.Millisecond}},
+		{"wait 60", Action{Kind: Wait, FVal: 60, Duration: time.Minute}},
+		{"speed 7", Action{Kind: Speed, FVal: 7}},
+		{"speed instant", Action{Kind: Speed}},
+		{"edge bounce", Action{Kind: Edge, SVal: EdgeBounce}},
+		{"costume turtle", Action{Kind: SetCostume, SVal: "turtle"}},
+
+		// the limits are inclusive
+		{"forward 1000", Action{Kind: Step, FVal: MaxDistance}},
+		{"goto -1000 1000", Action{Kind: Goto, FVal: -MaxDistance, FVal2: MaxDistance}},
+		{"circle 1000", Action{Kind: Circle, FVal: MaxDistance}},
+		{"arc -360 1000", Action{Kind: Arc, FVal: -MaxArc, FVal2: MaxDistance}},
+		{"polygon 360 1000", Action{Kind: Polygon, FVal: MaxSides, FVal2: MaxDistance}},
+		{"left 360", Action{Kind: Left, FVal: MaxTurn}},
+		{"width 100", Action{Kind: Width, FVal: MaxWidth}},
+		{"dot 100", Action{Kind: Dot, FVal: MaxWidth}},
+	}
+
+	for _, tt := range tests {
+		a, err := ParseCommand(tt.cmd, 7)
+		if err != nil {
+			t.Errorf("ParseCommand(%q): %v", tt.cmd, err)
+			continue
+		}
+
+		want := tt.want
+		if want.Cmd == "" {
+			want.Cmd = tt.cmd
+		}
+		want.Line = 7
+		if *a != want {
+			t.Errorf("ParseCommand(%q) = %+v, want %+v", tt.cmd, *a, want)
+		}
+	}
+}
+
+func TestParseCommandErrors(t *testing.T) {
+	tests := []struct {
+		cmd string
+		msg string
+	}{
+		{"", "unknown command"},
+		{"jump", "unknown command"},
+		{"forward -1", "unknown command"},
+		{"forward 1e3", "unknown command"},
+		{"color nosuchcolor", "unknown color"},
+		{"color rgb(256, 0, 0)", "rgb values must be between 0 and 255"},
+		{"fill nosuchcolor", "unknown color"},
+		{"gradient red", "a gradient needs two colors"},
+		{"gradient red green blue", "a gradient needs two colors"},
+		{"gradient red nosuchcolor", "unknown color"},
+		{"polygon 2 1", "a polygon needs at least 3 sides"},
+		{"opacity 1.5", "opacity must be between 0 and 1"},
+		{"speed 0", "speed must be from 1 to 10, or instant"},
+		{"speed 11", "speed must be from 1 to 10, or instant"},
+		{"cap pointy", "unknown command"},
+		{"edge bend", "unknown command"},
+
+		// limits
+		{"forward 1000.5", "distance must be at most 1000"},
+		{"forward 100000000", "distance must be at most 1000"},
+		{"goto 0 -1001", "distance must be at most 1000"},
+		{"setx 5000", "distance must be at most 1000"},
+		{"sety -5000", "distance must be at most 1000"},
+		{"circle 1001", "radius must be at most 1000"},
+		{"arc 361 1", "arc angle must be at most 360"},
+		{"arc -720 1", "arc angle must be at most 360"},
+		{"arc 90 1001", "radius must be at most 1000"},
+		{"polygon 361 1", "number of sides must be at most 360"},
+		{"polygon 2000000 0.001", "number of sides must be at most 360"},
+		{"polygon 6 1001", "side length must be at most 1000"},
+		{"wait 60.5", "wait must be at most 60"},
+		{"wait 99999999999", "wait must be at most 60"},
+		{"left 361", "turn angle must be at most 360"},
+		{"right 1000000000", "turn angle must be at most 360"},
+		{"width 1e9", "unknown command"},
+		{"width 1000000000", "width must be at most 100"},
+		{"dot 1000000000", "dot size must be at most 100"},
+	}
+
+	for _, tt := range tests {
+		a, err := ParseCommand(tt.cmd, 3)
+		if err == nil {
+			t.Errorf("ParseCommand(%q) = %+v, want an error", tt.cmd, *a)
+			continue
+		}
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("ParseCommand(%q) error is %T, want *ParseError", tt.cmd, err)
+			continue
+		}
+		if perr.Msg != tt.msg || perr.Line != 3 {
+			t.Errorf("ParseCommand(%q) error = %q at line %d, want %q at line 3", tt.cmd, perr.Msg, perr.Line, tt.msg)
+		}
+	}
+}
+
+func TestParseLines(t *testing.T) {
+	tests := []struct {
+		lines   []string
+		actions int
+		errors  []string
+	}{
+		{[]string{"draw mode", "forward", "", "right"}, 2, nil},
+		{[]string{"# notes", "draw mode", "forward", "jump", "width 1000"}, 1, []string{
+			`line 4: unknown command: "jump"`,
+			`line 5: width must be at most 100: "width 1000"`,
+		}},
+		{[]string{"draw mod", "forward"}, 0, []string{`missing the start line: "draw mode"`}},
+	}
+
+	for _, tt := range tests {
+		var errors []string
+		actor := parseLines("1", tt.lines, func(id string, err error) {
+			if id != "1" {
+				t.Errorf("parseLines(%q) reported an error for actor %q, want 1", tt.lines, id)
+			}
+			errors = append(errors, err.Error())
+		})
+		if len(actor.actions) != tt.actions {
+			t.Errorf("parseLines(%q) = %d actions, want %d", tt.lines, len(actor.actions), tt.actions)
+		}
+		if fmt.Sprint(errors) != fmt.Sprint(tt.errors) {
+			t.Errorf("parseLines(%q) errors = %q, want %q", tt.lines, errors, tt.errors)
+		}
+	}
+}
+
+func TestSeconds(t *testing.T) {
+	tests := []struct {
+		s    float64
+		want time.Duration
+	}{
+		{0, 0},
+		{0.25, 250 * time.Millisecond},
+		{60, MaxDuration},
+		{61, MaxDuration},
+		{1e12, MaxDuration},
+	}
+
+	for _, tt := range tests {
+		if got := Seconds(tt.s); got != tt.want {
+			t.Errorf("Seconds(%v) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
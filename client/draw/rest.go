@@ -3,15 +3,18 @@ package draw
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
-	"strconv"
-	"strings"
 )
 
-func NewHTTPActorsList(addr string) ActorsList {
+// NewHTTPActorsList returns a list of actors polled from the server at addr;
+// onError (if not nil) is called on connection and parse errors,
+// with an empty actor ID for errors not related to a particular actor
+func NewHTTPActorsList(addr string, onError func(id string, err error)) ActorsList {
 
 	return &HTTPActorList{
-		addr: addr,
+		addr:    addr,
+		onError: onError,
 	}
 }
 
@@ -19,38 +22,57 @@ var _ Actor = &HTTPActor{}
 
 type move struct {
 	Description string
+	Duration    float64 // in seconds, optional
 }
 
 type HTTPActor struct {
-	addr  string
-	moves []move
+	addr    string
+	moves   []move
+	line    int // number of moves received so far
+	onError func(id string, err error)
 
-	ArtistID string `json:"ID"`
-	Name     string
+	ArtistID   string `json:"ID"`
+	ArtistName string `json:"Name"`
 }
 
 type HTTPActorList struct {
-	actors []HTTPActor
-	addr   string
+	actors  []HTTPActor
+	addr    string
+	onError func(id string, err error)
+}
+
+// reportError passes the error to onError, or logs it if there's no callback
+func reportError(onError func(id string, err error), id string, err error) {
+	if onError == nil {
+		if id != "" {
+			err = fmt.Errorf("%s: %v", id, err)
+		}
+		log.Print(err)
+		return
+	}
+	onError(id, err)
 }
 
 func (s *HTTPActorList) Actors() []Actor {
 	resp, err := http.Get(s.addr + "/api/artists")
 	if err != nil {
-		fmt.Println("Err getting artists", err)
+		reportError(s.onError, "", fmt.Errorf("error getting artists: %v", err))
 		return nil
 	}
+	defer resp.Body.Close()
 
 	dec := json.NewDecoder(resp.Body)
 	err = dec.Decode(&s.actors)
 	if err != nil {
-		fmt.Println("Err decoding artists", err)
+		reportError(s.onError, "", fmt.Errorf("error decoding artists: %v", err))
 		return nil
 	}
 
 	actors := make([]Actor, len(s.actors))
 	for i, a := range s.actors {
-		s.actors[i].addr = s.addr
+		a := a
+		a.addr = s.addr
+		a.onError = s.onError
 		actors[i] = Actor(&a)
 	}
 
@@ -63,79 +85,47 @@ func (s *HTTPActor) Next() (*Action, bool) {
 	if len(s.moves) == 0 {
 		resp, err := http.Get(s.addr + "/api/artists/" + s.ArtistID + "/moves")
 		if err != nil {
-			fmt.Println("Err getting moves", err)
+			reportError(s.onError, s.ArtistID, fmt.Errorf("error getting moves: %v", err))
 			return nil, false
 		}
+		defer resp.Body.Close()
 
 		dec := json.NewDecoder(resp.Body)
 		err = dec.Decode(&s.moves)
 		if err != nil {
-			fmt.Println("Err decoding moves", err)
+			reportError(s.onError, s.ArtistID, fmt.Errorf("error decoding moves: %v", err))
 			return nil, false
 		}
 	}
 
-	if len(s.moves) > 0 {
-		action := parseDescription(s.moves[0].Description)
+	// skip (and report) moves that can't be parsed
+	for len(s.moves) > 0 {
+		s.line = s.line + 1
+		m := s.moves[0]
+		action, err := ParseCommand(m.Description, s.line)
 		s.moves = s.moves[1:len(s.moves)]
+		if err != nil {
+			reportError(s.onError, s.ArtistID, err)
+			continue
+		}
+		if m.Duration > 0 {
+			action.Duration = Seconds(m.Duration)
+		}
 		return action, true
 	}
 
 	return nil, false
 }
 
+// Queued returns the number of moves fetched but not returned yet
+func (s *HTTPActor) Queued() int {
+	return len(s.moves)
+}
+
 func (s *HTTPActor) ID() string {
 	return s.ArtistID
 }
 
-func parseDescription(line string) *Action {
-	var a *Action
-
-	line = strings.ToLower(strings.TrimSpace(line))
-
-	if matches := cmdForwardR.FindAllStringSubmatch(line, -1); matches != nil {
-		a = &Action{line, Step, 1, ""}
-	}
-
-	if matches := cmdForwardNR.FindAllStringSubmatch(line, -1); matches != nil {
-		n, _ := strconv.ParseFloat(matches[0][1], 64)
-		a = &Action{line, Step, n, ""}
-	}
-
-	if matches := cmdLeftR.FindAllStringSubmatch(line, -1); matches != nil {
-		a = &Action{line, Left, 90, ""}
-	}
-
-	if matches := cmdLeftNR.FindAllStringSubmatch(line, -1); matches != nil {
-		n, _ := strconv.ParseFloat(matches[0][1], 64)
-		a = &Action{line, Left, n, ""}
-	}
-
-	if matches := cmdRightR.FindAllStringSubmatch(line, -1); matches != nil {
-		a = &Action{line, Right, 90, ""}
-	}
-
-	if matches := cmdRightNR.FindAllStringSubmatch(line, -1); matches != nil {
-		n, _ := strconv.ParseFloat(matches[0][1], 64)
-		a = &Action{line, Right, n, ""}
-	}
-
-	if matches := cmdColorOffR.FindAllStringSubmatch(line, -1); matches != nil {
-		a = &Action{line, Color, 0, ""}
-	}
-
-	if matches := cmdColorSR.FindAllStringSubmatch(line, -1); matches != nil {
-		a = &Action{line, Color, 0, matches[0][1]}
-	}
-
-	if matches := cmdWidthNR.FindAllStringSubmatch(line, -1); matches != nil {
-		n, _ := strconv.ParseFloat(matches[0][1], 64)
-		a = &Action{line, Width, n, ""}
-	}
-
-	if matches := cmdSaySR.FindAllStringSubmatch(line, -1); matches != nil {
-		a = &Action{line, Say, 0, matches[0][1]}
-	}
-
-	return a
+func (s *HTTPActor) Name() string {
+	return s.ArtistName
 }
@@ -0,0 +1,232 @@
+package draw
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Session files record what happened on the board so that it can be
+// replayed later. A session file is a sequence of JSON objects, one per line
+// (JSON Lines), ordered by time. Each object describes a single event:
+//
+//	Time      milliseconds since the start of the recording
+//	Type      "join" when an artist joins the board,
+//	          "action" when an artist sends a command
+//	Artist    artist ID
+//	Name      artist name ("join" events only)
+//	Cmd       command text, e.g. "forward 5" ("action" events only)
+//	Duration  how long the action takes in milliseconds, if it was set
+//	          along with the command (optional, "action" events only)
+//
+// Example:
+//
+//	{"Time":0,"Type":"join","Artist":"artist1","Name":"Ann"}
+//	{"Time":1520,"Type":"action","Artist":"artist1","Cmd":"forward 5"}
+//	{"Time":2034,"Type":"action","Artist":"artist1","Cmd":"right"}
+
+// Session event types
+const (
+	EventJoin   = "join"
+	EventAction = "action"
+)
+
+// Event is a single line of a session file
+type Event struct {
+	Time     int64
+	Type     string
+	Artist   string
+	Name     string `json:",omitempty"`
+	Cmd      string `json:",omitempty"`
+	Duration int64  `json:",omitempty"`
+}
+
+// Recorder writes session events to a session file;
+// it is safe for concurrent use
+type Recorder struct {
+	mu    sync.Mutex
+	enc   *json.Encoder
+	start time.Time
+}
+
+// NewRecorder returns a recorder writing to w;
+// event times are counted from the moment of this call
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{
+		enc:   json.NewEncoder(w),
+		start: time.Now(),
+	}
+}
+
+func (r *Recorder) record(e Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e.Time = int64(time.Since(r.start) / time.Millisecond)
+	return r.enc.Encode(e)
+}
+
+// Join records an artist joining the board
+func (r *Recorder) Join(id, name string) error {
+	return r.record(Event{Type: EventJoin, Artist: id, Name: name})
+}
+
+// Command records a command sent by an artist;
+// d is the action duration if it was set along with the command
+func (r *Recorder) Command(id, cmd string, d time.Duration) error {
+	return r.record(Event{Type: EventAction, Artist: id, Cmd: cmd, Duration: int64(d / time.Millisecond)})
+}
+
+// ReadSession reads all the events from a session file, ordered by time
+// (events with the same time keep their order in the file)
+func ReadSession(r io.Reader) ([]Event, error) {
+	var events []Event
+
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		events = append(events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time < events[j].Time
+	})
+	return events, nil
+}
+
+var _ Actor = &ReplayActor{}
+
+// ReplayActor replays actions of a single artist from a session file
+type ReplayActor struct {
+	list    *ReplayActorList
+	id      string
+	name    string
+	joined  time.Duration
+	events  []Event
+	line    int
+	onError func(id string, err error)
+}
+
+// ReplayActorList replays a recorded session
+type ReplayActorList struct {
+	actors []*ReplayActor
+	speed  float64
+	start  time.Time
+}
+
+// NewReplayActorsList returns a list of actors replaying the session
+// read from r. Events are replayed with their original timing multiplied
+// by 1/speed; if speed is zero or negative, events are replayed
+// as fast as the board can draw them. onError (if not nil) is called
+// for commands that can't be parsed.
+func NewReplayActorsList(r io.Reader, speed float64, onError func(id string, err error)) (ActorsList, error) {
+	events, err := ReadSession(r)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &ReplayActorList{
+		speed: speed,
+	}
+
+	byID := make(map[string]*ReplayActor)
+	for _, e := range events {
+		a, ok := byID[e.Artist]
+		if !ok {
+			a = &ReplayActor{
+				list:    l,
+				id:      e.Artist,
+				name:    e.Artist,
+				joined:  time.Duration(e.Time) * time.Millisecond,
+				onError: onError,
+			}
+			byID[e.Artist] = a
+			l.actors = append(l.actors, a)
+		}
+
+		switch e.Type {
+		case EventJoin:
+			a.name = e.Name
+		case EventAction:
+			a.events = append(a.events, e)
+		}
+	}
+
+	return l, nil
+}
+
+// elapsed returns the session time reached by the replay so far
+func (l *ReplayActorList) elapsed() time.Duration {
+	if l.start.IsZero() {
+		l.start = time.Now()
+	}
+	if l.speed <= 0 {
+		return math.MaxInt64
+	}
+	return time.Duration(float64(time.Since(l.start)) * l.speed)
+}
+
+func (l *ReplayActorList) Actors() []Actor {
+	elapsed := l.elapsed()
+
+	var actors []Actor
+	for _, a := range l.actors {
+		if a.joined <= elapsed {
+			actors = append(actors, a)
+		}
+	}
+	return actors
+}
+
+func (s *ReplayActor) Next() (*Action, bool) {
+	elapsed := s.list.elapsed()
+
+	for len(s.events) > 0 && time.Duration(s.events[0].Time)*time.Millisecond <= elapsed {
+		s.line = s.line + 1
+		e := s.events[0]
+		action, err := ParseCommand(e.Cmd, s.line)
+		s.events = s.events[1:]
+		if err != nil {
+			reportError(s.onError, s.id, err)
+			continue
+		}
+		if e.Duration > 0 {
+			action.Duration = Seconds(float64(e.Duration) / 1000)
+		}
+		return action, true
+	}
+
+	return nil, false
+}
+
+// Queued returns the number of events due by now but not replayed yet
+func (s *ReplayActor) Queued() int {
+	elapsed := s.list.elapsed()
+	return sort.Search(len(s.events), func(i int) bool {
+		return time.Duration(s.events[i].Time)*time.Millisecond > elapsed
+	})
+}
+
+func (s *ReplayActor) ID() string {
+	return s.id
+}
+
+func (s *ReplayActor) Name() string {
+	return s.name
+}
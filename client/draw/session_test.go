@@ -0,0 +1,157 @@
+package draw
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewRecorder(&buf)
+	if err := r.Join("artist1", "Ann"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Command("artist1", "forward 5", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Command("artist1", "right", 1500*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := ReadSession(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the times depend on the clock; check that they are in order
+	for i := range events {
+		if i > 0 && events[i].Time < events[i-1].Time {
+			t.Errorf("event %d is at %d ms, before the previous one at %d ms", i, events[i].Time, events[i-1].Time)
+		}
+		events[i].Time = 0
+	}
+
+	want := []Event{
+		{Type: EventJoin, Artist: "artist1", Name: "Ann"},
+		{Type: EventAction, Artist: "artist1", Cmd: "forward 5"},
+		{Type: EventAction, Artist: "artist1", Cmd: "right", Duration: 1500},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("ReadSession() = %+v, want %+v", events, want)
+	}
+}
+
+func TestReadSession(t *testing.T) {
+	tests := []struct {
+		session string
+		want    []Event
+	}{
+		{"", nil},
+		{
+			`{"Time":0,"Type":"join","Artist":"a","Name":"Ann"}` + "\n\n" +
+				`{"Time":20,"Type":"action","Artist":"a","Cmd":"forward"}` + "\n",
+			[]Event{
+				{Time: 0, Type: EventJoin, Artist: "a", Name: "Ann"},
+				{Time: 20, Type: EventAction, Artist: "a", Cmd: "forward"},
+			},
+		},
+		{
+			// events out of order are sorted, keeping the order of equal times
+			`{"Time":30,"Type":"action","Artist":"a","Cmd":"left"}` + "\n" +
+				`{"Time":10,"Type":"action","Artist":"a","Cmd":"forward"}` + "\n" +
+				`{"Time":30,"Type":"action","Artist":"b","Cmd":"right"}` + "\n" +
+				`{"Time":0,"Type":"join","Artist":"a","Name":"Ann"}`,
+			[]Event{
+				{Time: 0, Type: EventJoin, Artist: "a", Name: "Ann"},
+				{Time: 10, Type: EventAction, Artist: "a", Cmd: "forward"},
+				{Time: 30, Type: EventAction, Artist: "a", Cmd: "left"},
+				{Time: 30, Type: EventAction, Artist: "b", Cmd: "right"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := ReadSession(strings.NewReader(tt.session))
+		if err != nil {
+			t.Errorf("ReadSession(%q): %v", tt.session, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ReadSession(%q) = %+v, want %+v", tt.session, got, tt.want)
+		}
+	}
+}
+
+func TestReadSessionErrors(t *testing.T) {
+	session := `{"Time":0,"Type":"join","Artist":"a","Name":"Ann"}` + "\n" + `{"Time":`
+
+	_, err := ReadSession(strings.NewReader(session))
+	if err == nil || !strings.HasPrefix(err.Error(), "line 2: ") {
+		t.Errorf("ReadSession(%q) error = %v, want a line 2 error", session, err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	session := strings.Join([]string{
+		`{"Time":0,"Type":"join","Artist":"a","Name":"Ann"}`,
+		`{"Time":5,"Type":"join","Artist":"b","Name":"Bob"}`,
+		`{"Time":10,"Type":"action","Artist":"a","Cmd":"forward 2"}`,
+		`{"Time":20,"Type":"action","Artist":"b","Cmd":"jump"}`,
+		`{"Time":30,"Type":"action","Artist":"a","Cmd":"wait 1","Duration":2500}`,
+		`{"Time":40,"Type":"action","Artist":"b","Cmd":"right","Duration":3600000}`,
+	}, "\n")
+
+	var errors []string
+	l, err := NewReplayActorsList(strings.NewReader(session), 0, func(id string, err error) {
+		errors = append(errors, id+": "+err.Error())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actors := l.Actors()
+	if len(actors) != 2 || actors[0].Name() != "Ann" || actors[1].Name() != "Bob" {
+		t.Fatalf("Actors() = %v, want Ann and Bob", actors)
+	}
+
+	want := map[string][]Action{
+		"a": {
+			{Line: 1, Cmd: "forward 2", Kind: Step, FVal: 2},
+			{Line: 2, Cmd: "wait 1", Kind: Wait, FVal: 1, Duration: 2500 * time.Millisecond},
+		},
+		"b": {
+			// the recorded duration is limited the same way as the `wait` one
+			{Line: 2, Cmd: "right", Kind: Right, FVal: 90, Duration: MaxDuration},
+		},
+	}
+	for _, actor := range actors {
+		a := actor.(*ReplayActor)
+
+		// everything is due at once, including the commands with errors
+		if n := a.Queued(); n != 2 {
+			t.Errorf("%s: Queued() = %d, want 2", a.ID(), n)
+		}
+
+		var got []Action
+		for {
+			action, ok := a.Next()
+			if !ok {
+				break
+			}
+			got = append(got, *action)
+		}
+		if !reflect.DeepEqual(got, want[a.ID()]) {
+			t.Errorf("%s: replayed %+v, want %+v", a.ID(), got, want[a.ID()])
+		}
+		if n := a.Queued(); n != 0 {
+			t.Errorf("%s: Queued() = %d after the replay, want 0", a.ID(), n)
+		}
+	}
+
+	if len(errors) != 1 || errors[0] != "b: "+(&ParseError{Line: 1, Cmd: "jump", Msg: "unknown command"}).Error() {
+		t.Errorf("errors = %q, want the `jump` error", errors)
+	}
+}
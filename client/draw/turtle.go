@@ -0,0 +1,633 @@
+package draw
+
+import (
+	"math"
+	"time"
+)
+
+// Turtle executes actions without any animation, keeping track
+// of the resulting position and pen state; it allows to render
+// drawings outside of the browser. Coordinates are in board units
+// (steps) with the Y axis pointing down; Angle is in degrees,
+// clockwise, with 0 pointing up (the same as on the board).
+type Turtle struct {
+	X, Y  float64
+	Angle float64
+	Color string // empty when the pen is off
+	Width float64
+	PenUp bool
+
+	// Dash is the dash and gap length in px (nil for solid lines);
+	// with a non-empty GradientTo, lines fade from Color to GradientTo
+	Dash       []float64
+	Opacity    float64
+	Cap        string
+	GradientTo string
+
+	// Speed is from 1 (slowest) to MaxSpeed, 0 for instant
+	Speed float64
+
+	// Board (if not nil) is the board area; Edge is what happens
+	// when the turtle reaches its edge (the Board.Edge if empty)
+	Board *Board
+	Edge  string
+
+	// FillColor is used by `endfill` (the pen color if empty);
+	// while filling, the traced path is collected into fillPath
+	FillColor string
+	filling   bool
+	fillRule  string
+	fillPath  []Segment
+}
+
+// Segment is a part of the path traced by the turtle: a straight line,
+// an arc, or a dot. Segments traced with the pen up have an empty Color.
+type Segment struct {
+	X1, Y1 float64
+	X2, Y2 float64
+
+	// Heading is the turtle heading at the start of the segment;
+	// it changes along arcs
+	Heading float64
+
+	// arcs are traced around the (CX, CY) center at the R radius,
+	// from the A1 angle (in radians, 0 pointing to the right), with Sweep
+	// being positive for clockwise arcs (the same as on the canvas)
+	Arc       bool
+	CX, CY, R float64
+	A1, Sweep float64
+
+	// dots are drawn at (X1, Y1) with the Width diameter
+	Dot bool
+
+	// fills are drawn with the Color inside the Outline path using
+	// the FillRule; the parts of the outline traced with the pen down
+	// are stroked on top of the fill
+	Outline  []Segment
+	FillRule string
+
+	Color string
+	Width float64
+
+	// Dash is the line dash pattern in px, and Offset is the distance
+	// (in steps) from the start of the segment it has been sliced from,
+	// so that the dashes of the slices line up
+	Dash    []float64
+	Offset  float64
+	Opacity float64
+	Cap     string
+
+	// with a non-empty GradientTo, the color changes from Color
+	// to GradientTo along the (GX1, GY1) - (GX2, GY2) line
+	GradientTo         string
+	GX1, GY1, GX2, GY2 float64
+}
+
+// Length returns the length of the segment in steps
+func (s Segment) Length() float64 {
+	if s.Arc {
+		return math.Abs(s.Sweep) * s.R
+	}
+	return math.Hypot(s.X2-s.X1, s.Y2-s.Y1)
+}
+
+// At returns the position and the turtle heading
+// at the distance d from the start of the segment
+func (s Segment) At(d float64) (x, y, heading float64) {
+	l := s.Length()
+	if l == 0 {
+		return s.X1, s.Y1, s.Heading
+	}
+	rel := d / l
+
+	if s.Arc {
+		a := s.A1 + s.Sweep*rel
+		return s.CX + s.R*math.Cos(a), s.CY + s.R*math.Sin(a),
+			s.Heading + s.Sweep*rel*180/math.Pi
+	}
+
+	return s.X1 + (s.X2-s.X1)*rel, s.Y1 + (s.Y2-s.Y1)*rel, s.Heading
+}
+
+// Slice returns the part of the segment between
+// the d1 and d2 distances from its start
+func (s Segment) Slice(d1, d2 float64) Segment {
+	l := s.Length()
+	if l == 0 {
+		return s
+	}
+
+	out := s
+	out.X1, out.Y1, out.Heading = s.At(d1)
+	out.X2, out.Y2, _ = s.At(d2)
+	out.Offset = s.Offset + d1
+	if s.Arc {
+		out.A1 = s.A1 + s.Sweep*d1/l
+		out.Sweep = s.Sweep * (d2 - d1) / l
+	}
+	return out
+}
+
+// Translate returns the segment moved by dx, dy
+func (s Segment) Translate(dx, dy float64) Segment {
+	s.X1, s.Y1 = s.X1+dx, s.Y1+dy
+	s.X2, s.Y2 = s.X2+dx, s.Y2+dy
+	s.CX, s.CY = s.CX+dx, s.CY+dy
+	s.GX1, s.GY1 = s.GX1+dx, s.GY1+dy
+	s.GX2, s.GY2 = s.GX2+dx, s.GY2+dy
+	if s.Outline != nil {
+		outline := make([]Segment, len(s.Outline))
+		for i, o := range s.Outline {
+			outline[i] = o.Translate(dx, dy)
+		}
+		s.Outline = outline
+	}
+	return s
+}
+
+// mirror returns the segment reflected by the vertical x = e line
+// (or by the horizontal y = e one)
+func (s Segment) mirror(vertical bool, e float64) Segment {
+	if vertical {
+		s.X1, s.X2, s.CX = 2*e-s.X1, 2*e-s.X2, 2*e-s.CX
+		s.GX1, s.GX2 = 2*e-s.GX1, 2*e-s.GX2
+		s.A1 = math.Pi - s.A1
+		s.Heading = -s.Heading
+	} else {
+		s.Y1, s.Y2, s.CY = 2*e-s.Y1, 2*e-s.Y2, 2*e-s.CY
+		s.GY1, s.GY2 = 2*e-s.GY1, 2*e-s.GY2
+		s.A1 = -s.A1
+		s.Heading = 180 - s.Heading
+	}
+	s.Sweep = -s.Sweep
+	return s
+}
+
+// PathLength returns the total length of the segments
+func PathLength(path []Segment) float64 {
+	l := 0.0
+	for _, s := range path {
+		l += s.Length()
+	}
+	return l
+}
+
+// DefaultColor is the pen color used by `pendown`
+// and `dot` if no color has been set; unlike the colors set
+// with commands, it's not resolved to #rrggbb, so that the board
+// can paint it with the default pen color of its theme
+const DefaultColor = "black"
+
+// DefaultSpeed is the initial turtle speed
+const DefaultSpeed = 3
+
+// StepDuration is how long a step (or a turn) takes at the DefaultSpeed
+const StepDuration = 500 * time.Millisecond
+
+// NewTurtle returns a turtle at the center of the board
+// with the default pen width and speed, and the pen off
+func NewTurtle() *Turtle {
+	return &Turtle{Width: 2, Opacity: 1, Cap: "butt", Speed: DefaultSpeed}
+}
+
+// Duration returns how long the action that has traced the path takes
+// at the turtle speed: moving takes a step duration per step, and turning
+// takes a step duration (a move with a turn like `home` takes whichever
+// is longer); turned tells if the turtle heading has changed
+func (t *Turtle) Duration(a *Action, path []Segment, turned bool) time.Duration {
+	if a.Duration > 0 {
+		return a.Duration
+	}
+	if t.Speed <= 0 {
+		return 0
+	}
+
+	step := time.Duration(float64(StepDuration) * DefaultSpeed / t.Speed)
+	d := time.Duration(float64(step) * PathLength(path))
+	turning := a.Kind == Left || a.Kind == Right || a.Kind == SetHeading || turned
+	if turning && d < step {
+		d = step
+	}
+	return d
+}
+
+// Apply executes the action and returns the path it has traced
+// (segments traced with the pen up or off have an empty Color)
+func (t *Turtle) Apply(a *Action) []Segment {
+	path := t.apply(a)
+	if t.filling {
+		for _, s := range path {
+			if !s.Dot {
+				t.fillPath = append(t.fillPath, s)
+			}
+		}
+	}
+	return path
+}
+
+func (t *Turtle) apply(a *Action) []Segment {
+	switch a.Kind {
+	case Step:
+		rad := (-90 + t.Angle) * 2 * math.Pi / 360
+		x := t.X + math.Cos(rad)*a.FVal
+		y := t.Y + math.Sin(rad)*a.FVal
+		return t.moveTo(x, y, true)
+	case Left:
+		t.Angle = t.Angle - a.FVal
+	case Right:
+		t.Angle = t.Angle + a.FVal
+	case Color:
+		if a.SVal != "" {
+			t.Color = resolveColor(a.SVal, t.Color)
+		} else {
+			t.Color = ""
+		}
+		t.GradientTo = ""
+	case Width:
+		t.Width = a.FVal
+	case Goto:
+		return t.moveTo(a.FVal, -a.FVal2, false)
+	case Home:
+		t.Angle = t.Angle + Turn(t.Angle, 0)
+		return t.moveTo(0, 0, false)
+	case SetHeading:
+		t.Angle = t.Angle + Turn(t.Angle, a.FVal)
+	case SetX:
+		return t.moveTo(a.FVal, t.Y, false)
+	case SetY:
+		return t.moveTo(t.X, -a.FVal, false)
+	case PenUp:
+		t.PenUp = true
+	case PenDown:
+		t.PenUp = false
+		if t.Color == "" {
+			t.Color = DefaultColor
+		}
+	case Circle:
+		path := t.arcPath(360, a.FVal)
+		t.keepOnBoard()
+		return path
+	case Arc:
+		path := t.arcPath(a.FVal, a.FVal2)
+		t.keepOnBoard()
+		return path
+	case Polygon:
+		return t.polygon(int(a.FVal), a.FVal2)
+	case Dot:
+		color := t.Color
+		if color == "" {
+			color = DefaultColor
+		}
+		size := a.FVal
+		if size == 0 {
+			size = math.Max(t.Width+4, t.Width*2)
+		}
+		return []Segment{{
+			X1: t.X, Y1: t.Y, X2: t.X, Y2: t.Y,
+			Heading: t.Angle,
+			Dot:     true,
+			Color:   color,
+			Width:   size,
+			Opacity: t.Opacity,
+		}}
+	case FillColor:
+		current := t.FillColor
+		if current == "" {
+			current = t.Color
+		}
+		t.FillColor = resolveColor(a.SVal, current)
+	case BeginFill:
+		t.filling = true
+		t.fillRule = a.SVal
+		t.fillPath = nil
+	case EndFill:
+		return t.endFill()
+	case Dash:
+		t.Dash = nil
+		if a.FVal > 0 {
+			t.Dash = []float64{a.FVal, a.FVal2}
+		}
+	case Opacity:
+		t.Opacity = a.FVal
+	case Cap:
+		t.Cap = a.SVal
+	case Speed:
+		t.Speed = a.FVal
+	case Edge:
+		t.Edge = a.SVal
+	case Gradient:
+		if a.SVal == "" {
+			t.GradientTo = ""
+			break
+		}
+		t.Color = resolveColor(a.SVal, t.Color)
+		t.GradientTo = resolveColor(a.SVal2, t.Color)
+	}
+	return nil
+}
+
+// endFill returns the fill of the path traced since `beginfill`
+func (t *Turtle) endFill() []Segment {
+	if !t.filling {
+		return nil
+	}
+	t.filling = false
+
+	color := t.FillColor
+	if color == "" {
+		color = t.Color
+	}
+	if color == "" {
+		color = DefaultColor
+	}
+
+	path := t.fillPath
+	t.fillPath = nil
+	if len(path) == 0 {
+		return nil
+	}
+
+	return []Segment{{
+		X1: t.X, Y1: t.Y, X2: t.X, Y2: t.Y,
+		Heading:  t.Angle,
+		Color:    color,
+		Opacity:  t.Opacity,
+		Outline:  path,
+		FillRule: t.fillRule,
+	}}
+}
+
+// Turn returns the shortest rotation (in degrees, positive is clockwise)
+// from the `from` heading to the `to` heading
+func Turn(from, to float64) float64 {
+	d := math.Mod(to-from, 360)
+	if d > 180 {
+		d = d - 360
+	}
+	if d <= -180 {
+		d = d + 360
+	}
+	return d
+}
+
+// pen sets the color (empty if the pen is up or off)
+// and the other pen attributes of the segment
+func (t *Turtle) pen(s *Segment) {
+	if !t.PenUp {
+		s.Color = t.Color
+	}
+	s.Width = t.Width
+	s.Dash = t.Dash
+	s.Opacity = t.Opacity
+	s.Cap = t.Cap
+	if s.Color != "" {
+		s.GradientTo = t.GradientTo
+	}
+}
+
+// moveTo moves the turtle to the x, y point, stopping at the edge
+// of the board, wrapping around it, or bouncing off it depending on
+// the edge mode; ahead tells if the turtle is moving along its heading
+// (so that it turns when bouncing)
+func (t *Turtle) moveTo(x, y float64, ahead bool) []Segment {
+	edge := t.edge()
+	if edge == EdgeNone {
+		return []Segment{t.line(x, y)}
+	}
+
+	var path []Segment
+	for i := 0; i < maxEdgeCrossings; i++ {
+		k, vertical := t.Board.exit(t.X, t.Y, x, y)
+		if k >= 1 {
+			break
+		}
+
+		ex, ey := t.X+(x-t.X)*k, t.Y+(y-t.Y)*k
+		if k > 0 {
+			path = append(path, t.line(ex, ey))
+		}
+
+		switch edge {
+		case EdgeClamp:
+			return path
+		case EdgeWrap:
+			// jump to the opposite edge
+			b := t.Board
+			switch {
+			case vertical && x > ex:
+				t.X, x = b.MinX, x-(b.MaxX-b.MinX)
+			case vertical:
+				t.X, x = b.MaxX, x+(b.MaxX-b.MinX)
+			case y > ey:
+				t.Y, y = b.MinY, y-(b.MaxY-b.MinY)
+			default:
+				t.Y, y = b.MaxY, y+(b.MaxY-b.MinY)
+			}
+		case EdgeBounce:
+			// the rest of the way is mirrored by the edge
+			if vertical {
+				x = 2*ex - x
+				if ahead {
+					t.Angle = -t.Angle
+				}
+			} else {
+				y = 2*ey - y
+				if ahead {
+					t.Angle = 180 - t.Angle
+				}
+			}
+		}
+	}
+
+	return append(path, t.line(x, y))
+}
+
+// arcPath traces the arc like arc does, stopping at the edge of
+// the board, wrapping around it, or bouncing off it depending on
+// the edge mode
+func (t *Turtle) arcPath(angle, r float64) []Segment {
+	s := t.arc(angle, r)
+	edge := t.edge()
+	if edge == EdgeNone || s.Length() == 0 {
+		return []Segment{s}
+	}
+
+	var path []Segment
+	bounced := false
+	for i := 0; i < maxEdgeCrossings; i++ {
+		d, vertical, e := t.Board.arcExit(s)
+		l := s.Length()
+		if d >= l {
+			break
+		}
+
+		if d > 0 {
+			path = append(path, s.Slice(0, d))
+		}
+		rest := s.Slice(d, l)
+
+		switch edge {
+		case EdgeClamp:
+			t.X, t.Y, t.Angle = rest.X1, rest.Y1, rest.Heading
+			return path
+		case EdgeWrap:
+			// the rest of the arc comes from the opposite edge
+			b := t.Board
+			switch {
+			case vertical && e == b.MaxX:
+				rest = rest.Translate(b.MinX-b.MaxX, 0)
+			case vertical:
+				rest = rest.Translate(b.MaxX-b.MinX, 0)
+			case e == b.MaxY:
+				rest = rest.Translate(0, b.MinY-b.MaxY)
+			default:
+				rest = rest.Translate(0, b.MaxY-b.MinY)
+			}
+		case EdgeBounce:
+			// the rest of the arc is mirrored by the edge
+			rest = rest.mirror(vertical, e)
+			bounced = true
+		}
+		s = rest
+	}
+
+	t.X, t.Y = s.X2, s.Y2
+	if bounced {
+		t.Angle = s.Heading + s.Sweep*180/math.Pi
+	}
+	return append(path, s)
+}
+
+// keepOnBoard moves the turtle back to the board
+// after tracing a shape that has left it
+func (t *Turtle) keepOnBoard() {
+	b := t.Board
+	switch t.edge() {
+	case EdgeNone:
+		return
+	case EdgeWrap:
+		w, h := b.MaxX-b.MinX, b.MaxY-b.MinY
+		t.X = b.MinX + math.Mod(math.Mod(t.X-b.MinX, w)+w, w)
+		t.Y = b.MinY + math.Mod(math.Mod(t.Y-b.MinY, h)+h, h)
+	default:
+		t.X = math.Max(b.MinX, math.Min(b.MaxX, t.X))
+		t.Y = math.Max(b.MinY, math.Min(b.MaxY, t.Y))
+	}
+}
+
+func (t *Turtle) edge() string {
+	switch {
+	case t.Board == nil:
+		return EdgeNone
+	case t.Edge != "":
+		return t.Edge
+	case t.Board.Edge != "":
+		return t.Board.Edge
+	}
+	return EdgeNone
+}
+
+// line traces the straight line to the x, y point
+func (t *Turtle) line(x, y float64) Segment {
+	s := Segment{
+		X1: t.X, Y1: t.Y, X2: x, Y2: y,
+		Heading: t.Angle,
+		GX1:     t.X, GY1: t.Y, GX2: x, GY2: y,
+	}
+	t.pen(&s)
+	t.X, t.Y = x, y
+	return s
+}
+
+// arc traces the `angle` degrees arc of the circle with the r radius,
+// turning right (or left, if the angle is negative)
+func (t *Turtle) arc(angle, r float64) Segment {
+	rad := t.Angle * math.Pi / 180
+	sweep := angle * math.Pi / 180
+
+	// the center is to the right of the turtle when turning right,
+	// and to the left otherwise
+	side := 1.0
+	if angle < 0 {
+		side = -1
+	}
+	cx := t.X + side*r*math.Cos(rad)
+	cy := t.Y + side*r*math.Sin(rad)
+	a1 := math.Atan2(t.Y-cy, t.X-cx)
+
+	s := Segment{
+		X1: t.X, Y1: t.Y,
+		Heading: t.Angle,
+		Arc:     true,
+		CX:      cx, CY: cy, R: r,
+		A1: a1, Sweep: sweep,
+	}
+	t.pen(&s)
+	s.X2, s.Y2, _ = s.At(s.Length())
+
+	// the gradient goes across the circle, from the start point
+	s.GX1, s.GY1 = t.X, t.Y
+	s.GX2, s.GY2 = 2*cx-t.X, 2*cy-t.Y
+
+	t.X, t.Y = s.X2, s.Y2
+	t.Angle = t.Angle + angle
+	return s
+}
+
+// polygon traces the regular polygon with the given number of sides
+// of the given size, turning right at each corner
+func (t *Turtle) polygon(sides int, size float64) []Segment {
+	if sides < 3 {
+		return nil
+	}
+
+	var path []Segment
+	turn := 360 / float64(sides)
+	for i := 0; i < sides; i++ {
+		if i > 0 {
+			// turn from the current heading, which may have
+			// changed by bouncing off an edge
+			t.Angle += turn
+		}
+		path = append(path, t.apply(&Action{Kind: Step, FVal: size})...)
+	}
+	t.Angle += turn
+	return path
+}
+
+// FollowsPath tells if the turtle heading follows the path traced
+// by the action (for shapes, and moves bouncing off the edges)
+// rather than turning evenly
+func FollowsPath(a *Action, path []Segment) bool {
+	return a.Kind == Circle || a.Kind == Arc || a.Kind == Polygon || len(path) > 1
+}
+
+// PathAt returns the position and the turtle heading
+// at the distance d from the start of the path
+func PathAt(path []Segment, d float64) (x, y, heading float64) {
+	for i, s := range path {
+		l := s.Length()
+		if d <= l || i == len(path)-1 {
+			return s.At(d)
+		}
+		d -= l
+	}
+	return 0, 0, 0
+}
+
+// SlicePath returns the part of the path between
+// the d1 and d2 distances from its start
+func SlicePath(path []Segment, d1, d2 float64) []Segment {
+	var out []Segment
+	offset := 0.0
+	for _, s := range path {
+		l := s.Length()
+		from := math.Max(d1, offset)
+		to := math.Min(d2, offset+l)
+		if to > from {
+			out = append(out, s.Slice(from-offset, to-offset))
+		}
+		offset += l
+	}
+	return out
+}
@@ -0,0 +1,248 @@
+package draw
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+const epsilon = 1e-9
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < epsilon
+}
+
+// run applies the commands to the turtle and returns the traced path
+func run(t *testing.T, tu *Turtle, cmds ...string) []Segment {
+	t.Helper()
+	var path []Segment
+	for i, cmd := range cmds {
+		a, err := ParseCommand(cmd, i+1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path = append(path, tu.Apply(a)...)
+	}
+	return path
+}
+
+func TestTurtleMoves(t *testing.T) {
+	tests := []struct {
+		cmds    []string
+		x, y    float64
+		angle   float64
+		segs    int
+		pathLen float64
+	}{
+		{[]string{"forward 3"}, 0, -3, 0, 1, 3},
+		{[]string{"right", "forward 2"}, 2, 0, 90, 1, 2},
+		{[]string{"left 45", "left 45", "forward"}, -1, 0, -90, 1, 1},
+		{[]string{"goto 3 4"}, 3, -4, 0, 1, 5},
+		{[]string{"goto 3 4", "home"}, 0, 0, 0, 2, 10},
+		{[]string{"right 270", "home"}, 0, 0, 360, 1, 0},
+		{[]string{"setx -2", "sety 2"}, -2, -2, 0, 2, 4},
+		{[]string{"setheading 270"}, 0, 0, -90, 0, 0},
+		{[]string{"right 170", "setheading -170"}, 0, 0, 190, 0, 0},
+		{[]string{"polygon 4 2"}, 0, 0, 360, 4, 8},
+		{[]string{"right 30", "polygon 360 1"}, 0, 0, 390, 360, 360},
+		{[]string{"circle 1"}, 0, 0, 360, 1, 2 * math.Pi},
+		{[]string{"arc 90 2"}, 2, -2, 90, 1, math.Pi},
+		{[]string{"arc -90 2"}, -2, -2, -90, 1, math.Pi},
+		{[]string{"arc 180 1000"}, 2000, 0, 180, 1, 1000 * math.Pi},
+	}
+
+	for _, tt := range tests {
+		tu := NewTurtle()
+		path := run(t, tu, tt.cmds...)
+		if !near(tu.X, tt.x) || !near(tu.Y, tt.y) || !near(tu.Angle, tt.angle) {
+			t.Errorf("%q: turtle at (%v, %v) heading %v, want (%v, %v) heading %v", tt.cmds, tu.X, tu.Y, tu.Angle, tt.x, tt.y, tt.angle)
+		}
+		if len(path) != tt.segs {
+			t.Errorf("%q: traced %d segments, want %d", tt.cmds, len(path), tt.segs)
+		}
+		if l := PathLength(path); math.Abs(l-tt.pathLen) > 1e-6 {
+			t.Errorf("%q: traced %v steps, want %v", tt.cmds, l, tt.pathLen)
+		}
+	}
+}
+
+func TestTurtlePolygon(t *testing.T) {
+	tu := NewTurtle()
+	path := run(t, tu, "pendown", "polygon 6 2")
+	if len(path) != 6 {
+		t.Fatalf("traced %d segments, want 6", len(path))
+	}
+
+	// the sides are joined and the last one ends where the first one starts
+	for i, s := range path {
+		next := path[(i+1)%len(path)]
+		if !near(s.X2, next.X1) || !near(s.Y2, next.Y1) {
+			t.Errorf("side %d ends at (%v, %v), side %d starts at (%v, %v)", i, s.X2, s.Y2, (i+1)%len(path), next.X1, next.Y1)
+		}
+		if !near(s.Length(), 2) {
+			t.Errorf("side %d is %v steps long, want 2", i, s.Length())
+		}
+		if s.Color != DefaultColor {
+			t.Errorf("side %d color = %q, want %q", i, s.Color, DefaultColor)
+		}
+	}
+}
+
+func TestTurtleArc(t *testing.T) {
+	tests := []struct {
+		cmd    string
+		cx, cy float64
+		r      float64
+		sweep  float64
+	}{
+		{"circle 2", 2, 0, 2, 2 * math.Pi},
+		{"arc 90 3", 3, 0, 3, math.Pi / 2},
+		{"arc -180 1", -1, 0, 1, -math.Pi},
+	}
+
+	for _, tt := range tests {
+		path := run(t, NewTurtle(), tt.cmd)
+		if len(path) != 1 || !path[0].Arc {
+			t.Errorf("%q: traced %+v, want an arc", tt.cmd, path)
+			continue
+		}
+		s := path[0]
+		if !near(s.CX, tt.cx) || !near(s.CY, tt.cy) || !near(s.R, tt.r) || !near(s.Sweep, tt.sweep) {
+			t.Errorf("%q: arc around (%v, %v), r = %v, sweep = %v; want (%v, %v), r = %v, sweep = %v",
+				tt.cmd, s.CX, s.CY, s.R, s.Sweep, tt.cx, tt.cy, tt.r, tt.sweep)
+		}
+	}
+}
+
+func TestTurtleEdges(t *testing.T) {
+	tests := []struct {
+		edge  string
+		cmds  []string
+		x, y  float64
+		angle float64
+		segs  int
+	}{
+		{EdgeNone, []string{"forward 20"}, 0, -20, 0, 1},
+		{EdgeClamp, []string{"forward 20"}, 0, -15, 0, 1},
+		{EdgeClamp, []string{"goto 100 100"}, 15, -15, 0, 1},
+		{EdgeClamp, []string{"circle 10"}, 15, -5 * math.Sqrt(3), 120, 1},
+		{EdgeWrap, []string{"forward 20"}, 0, 10, 0, 2},
+		{EdgeWrap, []string{"right", "forward 40"}, 10, 0, 90, 2},
+		{EdgeWrap, []string{"forward 1000"}, 0, -10, 0, 34},
+		{EdgeWrap, []string{"circle 10"}, 0, 0, 360, 3},
+		{EdgeBounce, []string{"forward 20"}, 0, -10, 180, 2},
+		{EdgeBounce, []string{"right", "forward 75"}, 15, 0, 90, 3},
+		{EdgeBounce, []string{"goto 20 0"}, 10, 0, 0, 2},
+		{EdgeBounce, []string{"arc 90 20"}, 10, -10, -90, 3},
+		{EdgeBounce, []string{"polygon 4 20"}, 0, 10, 180, 7},
+
+		// the edge command overrides the board edge mode
+		{EdgeNone, []string{"edge clamp", "forward 20"}, 0, -15, 0, 1},
+	}
+
+	for _, tt := range tests {
+		tu := NewTurtle()
+		tu.Board = NewBoard(0, 0, tt.edge)
+		path := run(t, tu, tt.cmds...)
+		if !near(tu.X, tt.x) || !near(tu.Y, tt.y) || !near(tu.Angle, tt.angle) {
+			t.Errorf("%s %q: turtle at (%v, %v) heading %v, want (%v, %v) heading %v", tt.edge, tt.cmds, tu.X, tu.Y, tu.Angle, tt.x, tt.y, tt.angle)
+		}
+		if len(path) != tt.segs {
+			t.Errorf("%s %q: traced %d segments, want %d", tt.edge, tt.cmds, len(path), tt.segs)
+		}
+	}
+}
+
+func TestTurtlePen(t *testing.T) {
+	tu := NewTurtle()
+	path := run(t, tu,
+		"forward", "color red", "forward", "penup", "forward", "pendown",
+		"color lime", "forward", "color off", "pendown", "forward")
+
+	want := []string{"", "#ff0000", "", "#00ff00", DefaultColor}
+	for i, s := range path {
+		if s.Color != want[i] {
+			t.Errorf("segment %d color = %q, want %q", i, s.Color, want[i])
+		}
+	}
+}
+
+func TestTurtleFill(t *testing.T) {
+	tu := NewTurtle()
+	path := run(t, tu, "color blue", "fill red", "beginfill evenodd", "polygon 5 2", "dot", "endfill", "endfill")
+
+	fill := path[len(path)-1]
+	if len(fill.Outline) != 5 || fill.Color != "#ff0000" || fill.FillRule != EvenOdd {
+		t.Errorf("endfill = %d segments of %q filled with %q, want 5 segments of %q filled with %q",
+			len(fill.Outline), fill.Color, fill.FillRule, "#ff0000", EvenOdd)
+	}
+	if n := len(path); n != 5+1+1 {
+		t.Errorf("traced %d segments, want 7 (the second endfill should trace nothing)", n)
+	}
+}
+
+func TestTurtleDuration(t *testing.T) {
+	tests := []struct {
+		speed float64
+		cmd   string
+		want  time.Duration
+	}{
+		{DefaultSpeed, "forward 2", 2 * StepDuration},
+		{DefaultSpeed, "right", StepDuration},
+		{DefaultSpeed, "goto 0 0.5", StepDuration / 2},
+		{DefaultSpeed, "polygon 4 0.1", StepDuration},
+		{2 * DefaultSpeed, "forward 2", StepDuration},
+		{0, "forward 2", 0},
+		{0, "wait 2", 2 * time.Second},
+		{DefaultSpeed, "wait 60", MaxDuration},
+	}
+
+	for _, tt := range tests {
+		tu := NewTurtle()
+		tu.Speed = tt.speed
+		a, err := ParseCommand(tt.cmd, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		angle := tu.Angle
+		path := tu.Apply(a)
+		if got := tu.Duration(a, path, tu.Angle != angle); got != tt.want {
+			t.Errorf("%q at speed %v takes %v, want %v", tt.cmd, tt.speed, got, tt.want)
+		}
+	}
+}
+
+func TestTurn(t *testing.T) {
+	tests := []struct {
+		from, to float64
+		want     float64
+	}{
+		{0, 90, 90},
+		{0, 270, -90},
+		{350, 10, 20},
+		{10, 350, -20},
+		{0, 180, 180},
+		{180, 0, 180},
+		{720, 0, 0},
+	}
+
+	for _, tt := range tests {
+		if got := Turn(tt.from, tt.to); !near(got, tt.want) {
+			t.Errorf("Turn(%v, %v) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestSlicePath(t *testing.T) {
+	path := run(t, NewTurtle(), "pendown", "forward 2", "right", "forward 2")
+
+	slice := SlicePath(path, 1, 3)
+	if len(slice) != 2 || !near(PathLength(slice), 2) {
+		t.Fatalf("SlicePath(1, 3) = %+v, want 2 segments 2 steps long", slice)
+	}
+
+	x, y, heading := PathAt(path, 3)
+	if !near(x, 1) || !near(y, -2) || !near(heading, 90) {
+		t.Errorf("PathAt(3) = (%v, %v) heading %v, want (1, -2) heading 90", x, y, heading)
+	}
+}
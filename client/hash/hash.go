@@ -1,38 +1,107 @@
 package hash
 
 import (
+	"math"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/gopherjs/gopherjs/js"
 	"github.com/iafan/goplayspace/client/js/history"
 )
 
-// Hash contains the state parsed from URL hash
+// Hash contains the state parsed from URL hash:
+//
+//	#room=math1&follow=artist3&zoom=2&grid=off&speed=4
+//
+// Every parameter is optional. The legacy `#<id>,<ranges>` form
+// is still understood, and may be followed by `&` and parameters.
 type Hash struct {
 	ID     string
 	Ranges string
 
+	// viewer state
+	Room      string
+	Follow    string  // artist followed by the camera
+	Spotlight string  // artist in the spotlight
+	Zoom      float64 // 0 if not set
+	Grid      string  // GridOn, GridOff, or empty if not set
+	Speed     float64 // board speed multiplier, 0 if not set
+
 	isUpdating bool
+	lastURL    string
 
 	OnChange func(h *Hash)
 }
 
+// Grid values
+const (
+	GridOn  = "on"
+	GridOff = "off"
+)
+
 func (h *Hash) updateAddressBar() {
+	u := h.url()
+	if u == h.lastURL {
+		return
+	}
+	h.lastURL = u
+
 	h.isUpdating = true
-	history.ReplaceState(h.url())
+	history.ReplaceState(u)
 	h.isUpdating = false
 }
 
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
+
+// parseFloat returns 0 for values that are missing,
+// can't be parsed or are not positive
+func parseFloat(s string) float64 {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f <= 0 || math.IsInf(f, 1) {
+		return 0
+	}
+	return f
+}
+
 func (h *Hash) url() string {
-	if h.ID == "" && h.Ranges == "" {
-		return "/"
+	s := h.ID
+	if h.Ranges != "" {
+		s += "," + h.Ranges
 	}
 
-	if h.ID != "" && h.Ranges == "" {
-		return "/#" + h.ID
+	params := url.Values{}
+	if h.Room != "" {
+		params.Set("room", h.Room)
+	}
+	if h.Follow != "" {
+		params.Set("follow", h.Follow)
+	}
+	if h.Spotlight != "" {
+		params.Set("spotlight", h.Spotlight)
+	}
+	if h.Zoom > 0 && h.Zoom != 1 {
+		params.Set("zoom", formatFloat(h.Zoom))
+	}
+	if h.Grid != "" {
+		params.Set("grid", h.Grid)
+	}
+	if h.Speed > 0 && h.Speed != 1 {
+		params.Set("speed", formatFloat(h.Speed))
+	}
+	if len(params) > 0 {
+		if s != "" {
+			s += "&"
+		}
+		s += params.Encode()
 	}
 
-	return "/#" + h.ID + "," + h.Ranges
+	if s == "" {
+		return "/"
+	}
+	return "/#" + s
 }
 
 // Reset resets the hash properties
@@ -54,6 +123,12 @@ func (h *Hash) SetRanges(ranges string) {
 	h.updateAddressBar()
 }
 
+// Update updates state (URL in the address bar)
+// after the viewer state fields have been changed
+func (h *Hash) Update() {
+	h.updateAddressBar()
+}
+
 func (h *Hash) onHashChange() {
 	if h.isUpdating {
 		return
@@ -65,12 +140,42 @@ func (h *Hash) onHashChange() {
 }
 
 func (h *Hash) parse() {
-	s := js.Global.Get("window").Get("location").Get("hash").String()[1:]
-	h.ID = s
+	h.parseString(js.Global.Get("window").Get("location").Get("hash").String())
+}
+
+// parseString fills the hash properties from the URL hash
+// (with or without the leading `#`)
+func (h *Hash) parseString(s string) {
+	s = strings.TrimPrefix(s, "#")
+
+	// `key=value` parameters follow the ID and ranges, if any
+	query := ""
+	if i := strings.Index(s, "&"); i >= 0 {
+		s, query = s[:i], s[i+1:]
+	}
+	if strings.Contains(s, "=") {
+		s, query = "", s+"&"+query
+	}
+
+	h.ID, h.Ranges = s, ""
 	if tokens := strings.SplitN(s, ",", 2); len(tokens) > 1 {
 		h.ID = tokens[0]
 		h.Ranges = tokens[1]
 	}
+
+	params, _ := url.ParseQuery(query)
+	h.Room = params.Get("room")
+	h.Follow = params.Get("follow")
+	h.Spotlight = params.Get("spotlight")
+	h.Zoom = parseFloat(params.Get("zoom"))
+	h.Grid = ""
+	switch grid := params.Get("grid"); grid {
+	case GridOn, GridOff:
+		h.Grid = grid
+	}
+	h.Speed = parseFloat(params.Get("speed"))
+
+	h.lastURL = h.url()
 }
 
 // New returns a new Hash instance filled with values
@@ -83,5 +188,3 @@ func New(onChange func(h *Hash)) *Hash {
 	js.Global.Get("window").Set("onhashchange", h.onHashChange)
 	return h
 }
-
-// FIXME: we need to somehow communicate changes from editor's Ranges object down to Hash.Ranges
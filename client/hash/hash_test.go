@@ -0,0 +1,89 @@
+package hash
+
+import "testing"
+
+// state is the part of the Hash kept in the URL
+type state struct {
+	ID, Ranges              string
+	Room, Follow, Spotlight string
+	Zoom                    float64
+	Grid                    string
+	Speed                   float64
+}
+
+func stateOf(h *Hash) state {
+	return state{h.ID, h.Ranges, h.Room, h.Follow, h.Spotlight, h.Zoom, h.Grid, h.Speed}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		hash string
+		want state
+	}{
+		{"", state{}},
+		{"#", state{}},
+		{"#abc", state{ID: "abc"}},
+		{"#abc,1-3,5", state{ID: "abc", Ranges: "1-3,5"}},
+		{
+			"#room=math1&follow=artist3&spotlight=artist2&zoom=2&grid=off&speed=4",
+			state{Room: "math1", Follow: "artist3", Spotlight: "artist2", Zoom: 2, Grid: GridOff, Speed: 4},
+		},
+		{"#grid=on", state{Grid: GridOn}},
+		{"#abc,1-3&zoom=1.5&grid=on", state{ID: "abc", Ranges: "1-3", Zoom: 1.5, Grid: GridOn}},
+		{"#room=a%20b", state{Room: "a b"}},
+
+		// invalid values are ignored
+		{"#grid=maybe", state{}},
+		{"#zoom=-2&speed=abc", state{}},
+		{"#zoom=0&speed=+Inf", state{}},
+	}
+
+	for _, tt := range tests {
+		var h Hash
+		h.parseString(tt.hash)
+		if got := stateOf(&h); got != tt.want {
+			t.Errorf("parse(%q) = %+v, want %+v", tt.hash, got, tt.want)
+		}
+	}
+}
+
+func TestURL(t *testing.T) {
+	tests := []struct {
+		h    *Hash
+		want string
+	}{
+		{&Hash{}, "/"},
+		{&Hash{ID: "abc"}, "/#abc"},
+		{&Hash{ID: "abc", Ranges: "1-3"}, "/#abc,1-3"},
+		{&Hash{Room: "math1", Grid: GridOn}, "/#grid=on&room=math1"},
+		{&Hash{ID: "abc", Follow: "artist3", Speed: 4}, "/#abc&follow=artist3&speed=4"},
+
+		// defaults are left out, floats are rounded
+		{&Hash{Zoom: 1, Speed: 1}, "/"},
+		{&Hash{Zoom: 1.23456}, "/#zoom=1.23"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.h.url(); got != tt.want {
+			t.Errorf("%+v.url() = %q, want %q", tt.h, got, tt.want)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	hashes := []*Hash{
+		{ID: "abc", Ranges: "1-3,7"},
+		{Room: "math 1", Follow: "artist3", Spotlight: "artist3", Zoom: 2.5, Grid: GridOff, Speed: 0.5},
+		{ID: "abc", Grid: GridOn},
+	}
+
+	for _, h := range hashes {
+		u := h.url()
+
+		var parsed Hash
+		parsed.parseString(u[len("/"):])
+		if got, want := stateOf(&parsed), stateOf(h); got != want {
+			t.Errorf("parse(%q) = %+v, want %+v", u, got, want)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package canvas
 
 import (
+	"math"
+
 	"github.com/gopherjs/gopherjs/js"
 )
 
@@ -23,3 +25,11 @@ func (c *Canvas) SetSize(w, h float64) {
 	c.Set("width", w)
 	c.Set("height", h)
 }
+
+// SetScaledSize sets the size of the canvas backing store to w, h CSS
+// pixels multiplied by ratio (usually the device pixel ratio),
+// so that the canvas is rendered without blurring on high-DPI screens
+func (c *Canvas) SetScaledSize(w, h, ratio float64) {
+	c.Set("width", math.Round(w*ratio))
+	c.Set("height", math.Round(h*ratio))
+}
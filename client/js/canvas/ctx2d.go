@@ -22,8 +22,62 @@ func (ctx *CanvasRenderingContext2D) SetStrokeStyle(style interface{}) {
 	ctx.Set("strokeStyle", style)
 }
 
+func (ctx *CanvasRenderingContext2D) SetGlobalAlpha(alpha float64) {
+	ctx.Set("globalAlpha", alpha)
+}
+
+// SetLineCap sets the line cap to "butt", "round" or "square"
+func (ctx *CanvasRenderingContext2D) SetLineCap(cap string) {
+	ctx.Set("lineCap", cap)
+}
+
+func (ctx *CanvasRenderingContext2D) SetLineDashOffset(offset float64) {
+	ctx.Set("lineDashOffset", offset)
+}
+
+// SetFilter sets the CSS filter applied to everything drawn
+// (an empty one for no filter)
+func (ctx *CanvasRenderingContext2D) SetFilter(filter string) {
+	if filter == "" {
+		filter = "none"
+	}
+	ctx.Set("filter", filter)
+}
+
+func (ctx *CanvasRenderingContext2D) SetFont(font string) {
+	ctx.Set("font", font)
+}
+
+func (ctx *CanvasRenderingContext2D) SetTextAlign(align string) {
+	ctx.Set("textAlign", align)
+}
+
+func (ctx *CanvasRenderingContext2D) SetTextBaseline(baseline string) {
+	ctx.Set("textBaseline", baseline)
+}
+
 // Methods
 
+func (ctx *CanvasRenderingContext2D) Save() {
+	ctx.Call("save")
+}
+
+func (ctx *CanvasRenderingContext2D) Restore() {
+	ctx.Call("restore")
+}
+
+// SetLineDash sets the dash pattern (an empty one for solid lines)
+func (ctx *CanvasRenderingContext2D) SetLineDash(segments []float64) {
+	if segments == nil {
+		segments = []float64{}
+	}
+	ctx.Call("setLineDash", segments)
+}
+
+func (ctx *CanvasRenderingContext2D) CreateLinearGradient(x0, y0, x1, y1 float64) *CanvasGradient {
+	return &CanvasGradient{ctx.Call("createLinearGradient", x0, y0, x1, y1)}
+}
+
 func (ctx *CanvasRenderingContext2D) BeginPath() {
 	ctx.Call("beginPath")
 }
@@ -40,6 +94,19 @@ func (ctx *CanvasRenderingContext2D) Translate(x, y float64) {
 	ctx.Call("translate", x, y)
 }
 
+func (ctx *CanvasRenderingContext2D) Scale(x, y float64) {
+	ctx.Call("scale", x, y)
+}
+
+// Rotate rotates the drawing clockwise by angle radians
+func (ctx *CanvasRenderingContext2D) Rotate(angle float64) {
+	ctx.Call("rotate", angle)
+}
+
+func (ctx *CanvasRenderingContext2D) SetTransform(a, b, c, d, e, f float64) {
+	ctx.Call("setTransform", a, b, c, d, e, f)
+}
+
 func (ctx *CanvasRenderingContext2D) MoveTo(x, y float64) {
 	ctx.Call("moveTo", x, y)
 }
@@ -51,3 +118,46 @@ func (ctx *CanvasRenderingContext2D) LineTo(x, y float64) {
 func (ctx *CanvasRenderingContext2D) Stroke() {
 	ctx.Call("stroke")
 }
+
+func (ctx *CanvasRenderingContext2D) Arc(x, y, r, startAngle, endAngle float64, anticlockwise bool) {
+	ctx.Call("arc", x, y, r, startAngle, endAngle, anticlockwise)
+}
+
+func (ctx *CanvasRenderingContext2D) ClosePath() {
+	ctx.Call("closePath")
+}
+
+// DrawImage draws the image (or canvas) scaled to the dw x dh rectangle
+func (ctx *CanvasRenderingContext2D) DrawImage(image *js.Object, dx, dy, dw, dh float64) {
+	ctx.Call("drawImage", image, dx, dy, dw, dh)
+}
+
+// DrawImageRect draws the sw x sh rectangle of the image (or canvas)
+// scaled to the dw x dh rectangle
+func (ctx *CanvasRenderingContext2D) DrawImageRect(image *js.Object, sx, sy, sw, sh, dx, dy, dw, dh float64) {
+	ctx.Call("drawImage", image, sx, sy, sw, sh, dx, dy, dw, dh)
+}
+
+func (ctx *CanvasRenderingContext2D) FillText(text string, x, y float64) {
+	ctx.Call("fillText", text, x, y)
+}
+
+// MeasureText returns the width of the text in px
+func (ctx *CanvasRenderingContext2D) MeasureText(text string) float64 {
+	return ctx.Call("measureText", text).Get("width").Float()
+}
+
+// Fill fills the current path using the "nonzero" or "evenodd" rule
+func (ctx *CanvasRenderingContext2D) Fill(rule string) {
+	ctx.Call("fill", rule)
+}
+
+// Rect adds a rectangle to the current path
+func (ctx *CanvasRenderingContext2D) Rect(x, y, w, h float64) {
+	ctx.Call("rect", x, y, w, h)
+}
+
+// Clip limits further painting to the current path
+func (ctx *CanvasRenderingContext2D) Clip() {
+	ctx.Call("clip")
+}
@@ -0,0 +1,13 @@
+package canvas
+
+import (
+	"github.com/gopherjs/gopherjs/js"
+)
+
+type CanvasGradient struct {
+	*js.Object
+}
+
+func (g *CanvasGradient) AddColorStop(offset float64, color string) {
+	g.Call("addColorStop", offset, color)
+}
@@ -0,0 +1,24 @@
+package download
+
+import (
+	"github.com/gopherjs/gopherjs/js"
+	"github.com/iafan/goplayspace/client/js/document"
+)
+
+// Save offers the user to save the content as a file
+func Save(filename, mimeType, content string) {
+	blob := js.Global.Get("Blob").New(
+		[]interface{}{content},
+		map[string]interface{}{"type": mimeType},
+	)
+	url := js.Global.Get("URL").Call("createObjectURL", blob)
+
+	a := document.CreateElement("a")
+	a.Set("href", url)
+	a.Set("download", filename)
+	document.Body().Call("appendChild", a)
+	a.Call("click")
+	document.Body().Call("removeChild", a)
+
+	js.Global.Get("URL").Call("revokeObjectURL", url)
+}
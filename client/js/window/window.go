@@ -1,6 +1,10 @@
 package window
 
-import "github.com/gopherjs/gopherjs/js"
+import (
+	"strconv"
+
+	"github.com/gopherjs/gopherjs/js"
+)
 
 // AddEventListener is a wrapper for window.addEventListener
 func AddEventListener(params ...interface{}) {
@@ -16,3 +20,29 @@ func RemoveEventListener(params ...interface{}) {
 func RequestAnimationFrame(callback interface{}) {
 	js.Global.Get("window").Call("requestAnimationFrame", callback)
 }
+
+// DevicePixelRatio returns window.devicePixelRatio
+// (1 if the browser doesn't support it)
+func DevicePixelRatio() float64 {
+	r := js.Global.Get("window").Get("devicePixelRatio")
+	if r == js.Undefined || r.Float() <= 0 {
+		return 1
+	}
+	return r.Float()
+}
+
+// OnDevicePixelRatioChange calls the callback once the device pixel ratio
+// changes from its current value (e.g. when the window is moved to a monitor
+// with a different resolution, or when the page is zoomed)
+func OnDevicePixelRatioChange(callback func()) {
+	query := "(resolution: " + strconv.FormatFloat(DevicePixelRatio(), 'f', -1, 64) + "dppx)"
+	mql := js.Global.Get("window").Call("matchMedia", query)
+
+	var listener *js.Object
+	listener = js.MakeFunc(func(this *js.Object, args []*js.Object) interface{} {
+		mql.Call("removeListener", listener)
+		callback()
+		return nil
+	})
+	mql.Call("addListener", listener)
+}
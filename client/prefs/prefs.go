@@ -0,0 +1,66 @@
+package prefs
+
+import (
+	"strconv"
+
+	"github.com/iafan/goplayspace/client/js/localstorage"
+	"github.com/iafan/goplayspace/client/theme"
+)
+
+// localStorage keys; the artist ones are shared with the student
+// controller, so they must not change
+const (
+	gridHiddenKey  = "gophers.gridHidden"
+	speedKey       = "gophers.speed"
+	themeKey       = "gophers.theme"
+	roomKey        = "gophers.room"
+	artistNameKey  = "gophers.artistName"
+	artistTokenKey = "gophers.artistToken"
+)
+
+// Prefs are the viewer preferences kept in localStorage
+// between page reloads
+type Prefs struct {
+	GridHidden bool
+	Speed      float64 // board speed multiplier
+	Theme      string
+	Room       string // the last room visited
+
+	// the student controller joins the board with these
+	// to keep drawing as the same artist
+	ArtistName  string
+	ArtistToken string
+}
+
+// Load returns the preferences saved before,
+// or the defaults for the ones that have never been saved
+func Load() Prefs {
+	speed, err := strconv.ParseFloat(localstorage.Get(speedKey, ""), 64)
+	if err != nil || speed <= 0 {
+		speed = 1
+	}
+
+	t := localstorage.Get(themeKey, theme.Light)
+	if !theme.Valid(t) {
+		t = theme.Light
+	}
+
+	return Prefs{
+		GridHidden:  localstorage.GetBool(gridHiddenKey, false),
+		Speed:       speed,
+		Theme:       t,
+		Room:        localstorage.Get(roomKey, ""),
+		ArtistName:  localstorage.Get(artistNameKey, ""),
+		ArtistToken: localstorage.Get(artistTokenKey, ""),
+	}
+}
+
+// Save saves the preferences
+func (p Prefs) Save() {
+	localstorage.Set(gridHiddenKey, strconv.FormatBool(p.GridHidden))
+	localstorage.Set(speedKey, strconv.FormatFloat(p.Speed, 'f', -1, 64))
+	localstorage.Set(themeKey, p.Theme)
+	localstorage.Set(roomKey, p.Room)
+	localstorage.Set(artistNameKey, p.ArtistName)
+	localstorage.Set(artistTokenKey, p.ArtistToken)
+}
@@ -0,0 +1,145 @@
+package ranges
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Range represents an inclusive range of 1-based line numbers
+type Range struct {
+	From int
+	To   int
+}
+
+// Ranges is a list of line ranges as represented in the URL hash
+// (e.g. "12-18,20" for lines 12 to 18 and line 20)
+type Ranges []Range
+
+// New parses the string representation of line ranges;
+// malformed tokens are silently skipped
+func New(s string) Ranges {
+	var r Ranges
+
+	for _, token := range strings.Split(s, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(token), "-", 2)
+
+		from, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil || from < 1 {
+			continue
+		}
+
+		to := from
+		if len(bounds) > 1 {
+			to, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+			if err != nil || to < 1 {
+				continue
+			}
+		}
+
+		r = append(r, newRange(from, to))
+	}
+
+	return r.normalize()
+}
+
+func newRange(from, to int) Range {
+	if to < from {
+		from, to = to, from
+	}
+	return Range{from, to}
+}
+
+// normalize sorts ranges and merges overlapping or adjacent ones
+func (r Ranges) normalize() Ranges {
+	if len(r) < 2 {
+		return r
+	}
+
+	sorted := make(Ranges, len(r))
+	copy(sorted, r)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].From < sorted[j].From
+	})
+
+	out := Ranges{sorted[0]}
+	for _, rr := range sorted[1:] {
+		last := &out[len(out)-1]
+		if rr.From <= last.To+1 {
+			if rr.To > last.To {
+				last.To = rr.To
+			}
+			continue
+		}
+		out = append(out, rr)
+	}
+	return out
+}
+
+// String returns the URL hash representation of ranges
+func (r Ranges) String() string {
+	tokens := make([]string, len(r))
+	for i, rr := range r {
+		tokens[i] = strconv.Itoa(rr.From)
+		if rr.To != rr.From {
+			tokens[i] += "-" + strconv.Itoa(rr.To)
+		}
+	}
+	return strings.Join(tokens, ",")
+}
+
+// Contains returns true if the line is within any of the ranges
+func (r Ranges) Contains(line int) bool {
+	for _, rr := range r {
+		if line >= rr.From && line <= rr.To {
+			return true
+		}
+	}
+	return false
+}
+
+// First returns the first selected line, or 0 if nothing is selected
+func (r Ranges) First() int {
+	if len(r) == 0 {
+		return 0
+	}
+	return r[0].From
+}
+
+// Extend stretches the most recently added range up to the given line
+// (shift+click behavior); if there are no ranges, a new one is created
+func (r Ranges) Extend(anchor, line int) Ranges {
+	if len(r) == 0 || anchor == 0 {
+		return Ranges{newRange(line, line)}
+	}
+	out := make(Ranges, 0, len(r))
+	for _, rr := range r {
+		if anchor < rr.From || anchor > rr.To {
+			out = append(out, rr)
+		}
+	}
+	return append(out, newRange(anchor, line)).normalize()
+}
+
+// Toggle adds the line to the selection or removes it
+// if it's already selected (ctrl/cmd+click behavior)
+func (r Ranges) Toggle(line int) Ranges {
+	if !r.Contains(line) {
+		return append(r, newRange(line, line)).normalize()
+	}
+
+	var out Ranges
+	for _, rr := range r {
+		if line < rr.From || line > rr.To {
+			out = append(out, rr)
+			continue
+		}
+		if rr.From < line {
+			out = append(out, Range{rr.From, line - 1})
+		}
+		if rr.To > line {
+			out = append(out, Range{line + 1, rr.To})
+		}
+	}
+	return out
+}
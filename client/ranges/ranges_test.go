@@ -0,0 +1,126 @@
+package ranges
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Ranges
+	}{
+		{"", nil},
+		{"5", Ranges{{5, 5}}},
+		{"12-18", Ranges{{12, 18}}},
+		{"12-18,20", Ranges{{12, 18}, {20, 20}}},
+		{" 3 , 7 - 9 ", Ranges{{3, 3}, {7, 9}}},
+
+		// reversed ranges are flipped
+		{"18-12", Ranges{{12, 18}}},
+
+		// ranges are sorted, and overlapping or adjacent ones are merged
+		{"20,1-3", Ranges{{1, 3}, {20, 20}}},
+		{"1-5,3-8", Ranges{{1, 8}}},
+		{"1-3,4-6", Ranges{{1, 6}}},
+		{"2-10,4-6", Ranges{{2, 10}}},
+		{"5,5,5", Ranges{{5, 5}}},
+		{"9-7,1-2,3", Ranges{{1, 3}, {7, 9}}},
+
+		// malformed tokens are skipped
+		{"abc", nil},
+		{"0", nil},
+		{"-3", nil},
+		{"3-", nil},
+		{"3-0", nil},
+		{"1-2-3", nil},
+		{"1.5", nil},
+		{"99999999999999999999", nil},
+		{",,4,x,6-y,", Ranges{{4, 4}}},
+	}
+
+	for _, tt := range tests {
+		if got := New(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("New(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		r    Ranges
+		want string
+	}{
+		{nil, ""},
+		{Ranges{{5, 5}}, "5"},
+		{Ranges{{12, 18}, {20, 20}}, "12-18,20"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.r.String(); got != tt.want {
+			t.Errorf("%v.String() = %q, want %q", tt.r, got, tt.want)
+		}
+		if got := New(tt.want); !reflect.DeepEqual(got, tt.r) {
+			t.Errorf("New(%q) = %v, want %v", tt.want, got, tt.r)
+		}
+	}
+}
+
+func TestContains(t *testing.T) {
+	r := New("3-5,9")
+	for line, want := range map[int]bool{0: false, 2: false, 3: true, 5: true, 6: false, 9: true, 10: false} {
+		if got := r.Contains(line); got != want {
+			t.Errorf("%v.Contains(%d) = %v, want %v", r, line, got, want)
+		}
+	}
+	if first := r.First(); first != 3 {
+		t.Errorf("%v.First() = %d, want 3", r, first)
+	}
+	if first := New("").First(); first != 0 {
+		t.Errorf("First() of no ranges = %d, want 0", first)
+	}
+}
+
+func TestExtend(t *testing.T) {
+	tests := []struct {
+		r            string
+		anchor, line int
+		want         string
+	}{
+		{"", 0, 7, "7"},
+		{"", 3, 7, "7"},
+		{"5", 5, 9, "5-9"},
+		{"5", 5, 2, "2-5"},
+		{"1-2,5-6", 5, 8, "1-2,5-8"},
+		{"1-2,5-6", 5, 1, "1-5"},
+		{"1-2,8", 5, 6, "1-2,5-6,8"},
+	}
+
+	for _, tt := range tests {
+		if got := New(tt.r).Extend(tt.anchor, tt.line).String(); got != tt.want {
+			t.Errorf("New(%q).Extend(%d, %d) = %q, want %q", tt.r, tt.anchor, tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestToggle(t *testing.T) {
+	tests := []struct {
+		r    string
+		line int
+		want string
+	}{
+		{"", 4, "4"},
+		{"4", 4, ""},
+		{"1-3", 4, "1-4"},
+		{"1-3", 6, "1-3,6"},
+		{"1-5", 1, "2-5"},
+		{"1-5", 5, "1-4"},
+		{"1-5", 3, "1-2,4-5"},
+	}
+
+	for _, tt := range tests {
+		if got := New(tt.r).Toggle(tt.line).String(); got != tt.want {
+			t.Errorf("New(%q).Toggle(%d) = %q, want %q", tt.r, tt.line, got, tt.want)
+		}
+	}
+}
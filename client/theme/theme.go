@@ -0,0 +1,77 @@
+package theme
+
+// Theme names; the page body gets the theme name as a CSS class,
+// which sets the colors of the page, the board background,
+// speech bubbles and name tags
+const (
+	Light        = "light"
+	Dark         = "dark"
+	HighContrast = "high-contrast"
+)
+
+// Names lists the themes, the default one first
+var Names = []string{Light, Dark, HighContrast}
+
+// Board defines the board colors painted on the canvas
+type Board struct {
+	GridWidth  float64 // in px
+	Grid       string
+	FifthGrid  string // every 5th line
+	CenterGrid string // lines crossing the center of the board
+
+	Pen string // the default pen color
+
+	// name tags painted on the board when there are too many
+	// gophers to show them as elements
+	NameTag     string
+	NameTagText string
+}
+
+var boards = map[string]Board{
+	Light: {
+		GridWidth:  1,
+		Grid:       "rgba(0, 0, 0, 0.05)",
+		FifthGrid:  "rgba(0, 0, 0, 0.09)",
+		CenterGrid: "rgba(0, 0, 0, 0.16)",
+		Pen:        "#000000",
+
+		NameTag:     "rgba(255, 255, 255, 0.75)",
+		NameTagText: "#000000",
+	},
+	Dark: {
+		GridWidth:  1,
+		Grid:       "rgba(255, 255, 255, 0.06)",
+		FifthGrid:  "rgba(255, 255, 255, 0.11)",
+		CenterGrid: "rgba(255, 255, 255, 0.22)",
+		Pen:        "#eeeeee",
+
+		NameTag:     "rgba(0, 0, 0, 0.6)",
+		NameTagText: "#eeeeee",
+	},
+	// washed-out projectors need bolder lines
+	HighContrast: {
+		GridWidth:  1.5,
+		Grid:       "rgba(0, 0, 0, 0.18)",
+		FifthGrid:  "rgba(0, 0, 0, 0.35)",
+		CenterGrid: "rgba(0, 0, 0, 0.6)",
+		Pen:        "#000000",
+
+		NameTag:     "#000000",
+		NameTagText: "#ffffff",
+	},
+}
+
+// Valid returns true if there's a theme with the given name
+func Valid(name string) bool {
+	_, ok := boards[name]
+	return ok
+}
+
+// BoardColors returns the board colors of the theme
+// (of the default theme if there's no theme with the given name)
+func BoardColors(name string) Board {
+	if b, ok := boards[name]; ok {
+		return b
+	}
+	return boards[Light]
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/lzw"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+)
+
+// gifWriter writes an animated GIF frame by frame, so that only
+// the previous frame is kept in memory. Each frame only covers
+// the part of the image that has changed since the previous one.
+type gifWriter struct {
+	w      *bufio.Writer
+	bounds image.Rectangle
+	pal    color.Palette
+	bits   int // palette size is 1<<bits
+
+	prev    *image.RGBA // the previous frame, nil before the first one
+	indices map[color.RGBA]uint8
+	buf     []byte
+}
+
+// newGIFWriter writes the GIF header with the global palette
+// (up to 256 colors) and makes the animation loop forever
+func newGIFWriter(w io.Writer, bounds image.Rectangle, pal color.Palette) (*gifWriter, error) {
+	if len(pal) == 0 || len(pal) > 256 {
+		return nil, errors.New("gif: the palette must have 1 to 256 colors")
+	}
+
+	g := &gifWriter{
+		w:       bufio.NewWriter(w),
+		bounds:  bounds,
+		pal:     pal,
+		bits:    1,
+		indices: make(map[color.RGBA]uint8),
+	}
+	for 1<<g.bits < len(pal) {
+		g.bits++
+	}
+
+	g.w.WriteString("GIF89a")
+
+	// logical screen descriptor with a global color table
+	g.writeUint16(bounds.Dx())
+	g.writeUint16(bounds.Dy())
+	g.w.WriteByte(0x80 | 0x70 | byte(g.bits-1))
+	g.w.WriteByte(0) // background color index
+	g.w.WriteByte(0) // pixel aspect ratio
+
+	for i := 0; i < 1<<g.bits; i++ {
+		var r, gg, b uint32
+		if i < len(pal) {
+			r, gg, b, _ = pal[i].RGBA()
+		}
+		g.w.Write([]byte{byte(r >> 8), byte(gg >> 8), byte(b >> 8)})
+	}
+
+	// loop forever
+	g.w.Write([]byte{0x21, 0xff, 0x0b})
+	g.w.WriteString("NETSCAPE2.0")
+	g.w.Write([]byte{0x03, 0x01, 0x00, 0x00, 0x00})
+
+	return g, g.w.Flush()
+}
+
+// writeFrame writes the frame shown for delay hundredths of a second
+func (g *gifWriter) writeFrame(frame *image.RGBA, delay int) error {
+	rect := g.changed(frame)
+	if g.prev == nil {
+		g.prev = image.NewRGBA(g.bounds)
+	}
+	copy(g.prev.Pix, frame.Pix)
+
+	// graphic control extension: keep the previous frame under this one
+	g.w.Write([]byte{0x21, 0xf9, 0x04, 0x01 << 2})
+	g.writeUint16(delay)
+	g.w.Write([]byte{0x00, 0x00})
+
+	// image descriptor without a local color table
+	g.w.WriteByte(0x2c)
+	g.writeUint16(rect.Min.X - g.bounds.Min.X)
+	g.writeUint16(rect.Min.Y - g.bounds.Min.Y)
+	g.writeUint16(rect.Dx())
+	g.writeUint16(rect.Dy())
+	g.w.WriteByte(0)
+
+	litWidth := g.bits
+	if litWidth < 2 {
+		litWidth = 2
+	}
+	g.w.WriteByte(byte(litWidth))
+
+	bw := &blockWriter{w: g.w}
+	lw := lzw.NewWriter(bw, lzw.LSB, litWidth)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		g.buf = g.buf[:0]
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			g.buf = append(g.buf, g.index(frame.RGBAAt(x, y)))
+		}
+		if _, err := lw.Write(g.buf); err != nil {
+			return err
+		}
+	}
+	if err := lw.Close(); err != nil {
+		return err
+	}
+	if err := bw.close(); err != nil {
+		return err
+	}
+
+	// send the frame right away, which also fails
+	// and stops the rendering if the client is gone
+	return g.w.Flush()
+}
+
+// close writes the GIF trailer
+func (g *gifWriter) close() error {
+	g.w.WriteByte(0x3b)
+	return g.w.Flush()
+}
+
+// changed returns the smallest rectangle containing all the pixels
+// that differ from the previous frame (a single pixel if none do)
+func (g *gifWriter) changed(frame *image.RGBA) image.Rectangle {
+	if g.prev == nil {
+		return g.bounds
+	}
+
+	rowChanged := func(y int) bool {
+		i := frame.PixOffset(g.bounds.Min.X, y)
+		return !bytes.Equal(frame.Pix[i:i+4*g.bounds.Dx()], g.prev.Pix[i:i+4*g.bounds.Dx()])
+	}
+
+	minY, maxY := g.bounds.Min.Y, g.bounds.Max.Y
+	for minY < maxY && !rowChanged(minY) {
+		minY++
+	}
+	if minY == maxY {
+		return image.Rect(g.bounds.Min.X, g.bounds.Min.Y, g.bounds.Min.X+1, g.bounds.Min.Y+1)
+	}
+	for !rowChanged(maxY - 1) {
+		maxY--
+	}
+
+	minX, maxX := g.bounds.Max.X, g.bounds.Min.X
+	for y := minY; y < maxY; y++ {
+		for x := g.bounds.Min.X; x < minX; x++ {
+			if frame.RGBAAt(x, y) != g.prev.RGBAAt(x, y) {
+				minX = x
+				break
+			}
+		}
+		for x := g.bounds.Max.X - 1; x >= maxX; x-- {
+			if frame.RGBAAt(x, y) != g.prev.RGBAAt(x, y) {
+				maxX = x + 1
+				break
+			}
+		}
+	}
+
+	return image.Rect(minX, minY, maxX, maxY)
+}
+
+// index returns the palette index of the closest color
+func (g *gifWriter) index(c color.RGBA) uint8 {
+	i, ok := g.indices[c]
+	if !ok {
+		i = uint8(g.pal.Index(c))
+		g.indices[c] = i
+	}
+	return i
+}
+
+func (g *gifWriter) writeUint16(v int) {
+	g.w.Write([]byte{byte(v), byte(v >> 8)})
+}
+
+// blockWriter splits the image data into sub-blocks of up to 255 bytes
+type blockWriter struct {
+	w   *bufio.Writer
+	buf [256]byte
+	n   int
+}
+
+func (b *blockWriter) Write(p []byte) (int, error) {
+	for _, c := range p {
+		b.n++
+		b.buf[b.n] = c
+		if b.n == 255 {
+			if err := b.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(p), nil
+}
+
+func (b *blockWriter) flush() error {
+	if b.n == 0 {
+		return nil
+	}
+	b.buf[0] = byte(b.n)
+	_, err := b.w.Write(b.buf[:b.n+1])
+	b.n = 0
+	return err
+}
+
+// close writes the remaining data and the block terminator
+func (b *blockWriter) close() error {
+	if err := b.flush(); err != nil {
+		return err
+	}
+	return b.w.WriteByte(0)
+}
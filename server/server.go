@@ -3,19 +3,29 @@ package main
 import (
 	"encoding/json"
 	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"sync"
 	"sync/atomic"
 
 	"github.com/gorilla/mux"
+	"github.com/iafan/goplayspace/client/draw"
 )
 
 const staticDir = "../static"
 
 func main() {
 	port := flag.Int("p", 8080, "port to listen at")
+	record := flag.String("record", "", "record session events (artists joining and their moves) to this file, overwriting it")
+	timelapse := flag.String("timelapse", "", "render a time-lapse GIF of an artist's drawing from this session file and exit")
+	timelapseArtist := flag.String("artist", "", "artist ID for -timelapse (can be omitted if there's only one artist in the session)")
+	timelapseOut := flag.String("o", "timelapse.gif", "output file for -timelapse")
+	timelapseSize := flag.Int("size", defaultTimelapseSize, "time-lapse width and height in px")
+	timelapseFPS := flag.Int("fps", defaultTimelapseFPS, "time-lapse frame rate")
+	timelapseEdge := flag.String("edge", draw.EdgeNone, "time-lapse board edge mode for artists that haven't set one: none, clamp, wrap or bounce")
 	help := flag.Bool("h", false, "show this help")
 
 	flag.Parse()
@@ -25,10 +35,41 @@ func main() {
 		return
 	}
 
+	f, err := os.Open(staticDir + "/costumes/costumes.json")
+	if err == nil {
+		costumes, err = draw.ReadCostumes(f)
+		f.Close()
+	}
+	if err != nil {
+		log.Printf("Can't load costumes: %v", err)
+	}
+
+	if *timelapse != "" {
+		opts := timelapseOptions{Size: *timelapseSize, FPS: *timelapseFPS, Edge: *timelapseEdge}
+		if err := runTimelapseCommand(*timelapse, *timelapseArtist, *timelapseOut, opts); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Saved the time-lapse to %s", *timelapseOut)
+		return
+	}
+
+	if *record != "" {
+		// event times and artist IDs start over with every server run,
+		// so a session file can't be continued
+		f, err := os.Create(*record)
+		if err != nil {
+			log.Fatalf("Can't open session file: %v", err)
+		}
+		defer f.Close()
+		recorder = draw.NewRecorder(f)
+		log.Printf("Recording the session to %s", *record)
+	}
+
 	log.Printf("Listening on http://localhost:%d/", *port)
 
 	artists = make(map[string]Artist)
 	moves = make(map[string][]Move)
+	history = make(map[string][]Move)
 
 	r := mux.NewRouter()
 	apiR := r.PathPrefix("/api/").Subrouter()
@@ -36,6 +77,7 @@ func main() {
 	apiR.HandleFunc("/artists", ArtistsHandler).Methods(http.MethodGet)
 	apiR.HandleFunc("/artists/{artistID}/moves", CreateMoveHandler).Methods(http.MethodPost)
 	apiR.HandleFunc("/artists/{artistID}/moves", MovesHandler).Methods(http.MethodGet)
+	apiR.HandleFunc("/artists/{artistID}/timelapse.gif", TimelapseHandler).Methods(http.MethodGet)
 
 	r.PathPrefix("/").Handler(http.FileServer(http.Dir(staticDir)))
 
@@ -53,13 +95,15 @@ func main() {
 }
 
 type Artist struct {
-	ID   string
-	Name string
+	ID      string
+	Name    string
+	Costume string `json:",omitempty"`
 }
 
 type Move struct {
 	ID          string
 	Description string
+	Duration    float64 `json:",omitempty"` // in seconds, overrides the artist speed
 
 	retreived bool
 }
@@ -67,16 +111,31 @@ type Move struct {
 var (
 	artistsCount int32
 	moveCount    int32
-	artists      map[string]Artist
-	moves        map[string][]Move
+
+	// mu guards artists, moves and history,
+	// which are used by concurrent requests
+	mu       sync.RWMutex
+	artists  map[string]Artist
+	moves    map[string][]Move
+	history  map[string][]Move // all the moves ever made, for time-lapses
+	recorder *draw.Recorder
+	costumes draw.Costumes
 )
 
+func recordEvent(err error) {
+	if err != nil {
+		log.Printf("Error recording the session: %v", err)
+	}
+}
+
 func ArtistsHandler(w http.ResponseWriter, r *http.Request) {
 
 	var aa []Artist
+	mu.RLock()
 	for _, a := range artists {
 		aa = append(aa, a)
 	}
+	mu.RUnlock()
 
 	w.Header().Add("Content-Type", "application/json")
 	encoder := json.NewEncoder(w)
@@ -94,11 +153,37 @@ func CreateArtistsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the costume is put on with a move, so that the board
+	// (and session files) get it the same way as later changes
+	initial := []Move{{Description: "say " + artist.Name}}
+	if artist.Costume != "" {
+		costume := Move{Description: "costume " + artist.Costume}
+		a, err := draw.ParseCommand(costume.Description, 0)
+		if err == nil {
+			err = costumes.Check(a)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		initial = append(initial, costume)
+	}
+
 	id := "artist" + strconv.Itoa(int(atomic.AddInt32(&artistsCount, 1)))
 
 	artist.ID = id
+	mu.Lock()
 	artists[id] = artist
-	moves[id] = append(moves[id], Move{Description: "say " + artist.Name})
+	moves[id] = append(moves[id], initial...)
+	history[id] = append(history[id], initial...)
+
+	if recorder != nil {
+		recordEvent(recorder.Join(id, artist.Name))
+		for _, m := range initial {
+			recordEvent(recorder.Command(id, m.Description, 0))
+		}
+	}
+	mu.Unlock()
 
 	w.Header().Add("Content-Type", "application/json")
 	encoder := json.NewEncoder(w)
@@ -108,20 +193,24 @@ func CreateArtistsHandler(w http.ResponseWriter, r *http.Request) {
 func MovesHandler(w http.ResponseWriter, r *http.Request) {
 	artistID := mux.Vars(r)["artistID"]
 
-	w.Header().Add("Content-Type", "application/json")
+	mu.Lock()
 	readyMoves, ok := moves[artistID]
 	if !ok {
 		readyMoves = []Move{}
 	}
+	delete(moves, artistID)
+	mu.Unlock()
+
+	w.Header().Add("Content-Type", "application/json")
 	encoder := json.NewEncoder(w)
 	encoder.Encode(readyMoves)
-
-	delete(moves, artistID)
 }
 
 func CreateMoveHandler(w http.ResponseWriter, r *http.Request) {
 	artistID := mux.Vars(r)["artistID"]
+	mu.RLock()
 	_, ok := artists[artistID]
+	mu.RUnlock()
 	if !ok {
 		w.WriteHeader(http.StatusNotFound)
 		return
@@ -139,8 +228,36 @@ func CreateMoveHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if move.Duration < 0 || move.Duration > draw.MaxDuration.Seconds() {
+		http.Error(w, fmt.Sprintf("duration must be between 0 and %v seconds", draw.MaxDuration.Seconds()), http.StatusBadRequest)
+		return
+	}
+
+	a, err := draw.ParseCommand(move.Description, 0)
+	if err == nil {
+		err = costumes.Check(a)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	move.Description = draw.ResolveRandomColors(move.Description)
+
 	move.ID = moveID
+	mu.Lock()
+	if a.Kind == draw.SetCostume {
+		artist := artists[artistID]
+		artist.Costume = a.SVal
+		artists[artistID] = artist
+	}
+
 	moves[artistID] = append(moves[artistID], move)
+	history[artistID] = append(history[artistID], move)
+
+	if recorder != nil {
+		recordEvent(recorder.Command(artistID, move.Description, draw.Seconds(move.Duration)))
+	}
+	mu.Unlock()
 
 	w.Header().Add("Content-Type", "application/json")
 	encoder := json.NewEncoder(w)
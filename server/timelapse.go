@@ -0,0 +1,898 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	imgdraw "image/draw"
+	"image/png"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/iafan/goplayspace/client/draw"
+)
+
+const (
+	defaultTimelapseSize = 400
+	defaultTimelapseFPS  = 10
+	minTimelapseSize     = 64
+	maxTimelapseSize     = 1024
+	maxTimelapseFPS      = 50
+
+	// longer drawings are sped up to fit into this number of frames
+	maxTimelapseFrames = 1000
+
+	// the number of pixels in all the frames together is limited too,
+	// so that bigger time-lapses get fewer frames
+	maxTimelapsePixels = 100 * 1000 * 1000
+
+	// time-lapses rendered at the same time; other requests wait
+	// for up to timelapseQueueTimeout and then get turned down
+	maxConcurrentTimelapses = 2
+	timelapseQueueTimeout   = 30 * time.Second
+
+	// minimum number of steps visible in each direction from the center
+	timelapseMinExtent = 5
+
+	// big drawings only get every 5th (25th, ...) grid line,
+	// so that there are no more lines than that
+	maxTimelapseGridLines = 100
+
+	// how long the finished drawing is shown before the animation loops,
+	// in 100ths of a second
+	timelapseLastFrameDelay = 300
+)
+
+var (
+	timelapseBgColor     = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	timelapseBoardColor  = color.RGBA{0xf2, 0xf2, 0xf2, 0xff} // rgba(0, 0, 0, 0.05) on white
+	timelapseFifthColor  = color.RGBA{0xe8, 0xe8, 0xe8, 0xff} // rgba(0, 0, 0, 0.09) on white
+	timelapseCenterColor = color.RGBA{0xd6, 0xd6, 0xd6, 0xff} // rgba(0, 0, 0, 0.16) on white
+
+	// the number of sprite colors put into the palette
+	spritePaletteColors = 8
+)
+
+// timelapseSlots limits the number of time-lapses rendered at the same time
+var timelapseSlots = make(chan struct{}, maxConcurrentTimelapses)
+
+// costumeSheetsFS has the costume sprite sheets from static/
+// rasterized at twice the costume size, named after the costumes
+//
+//go:embed costumes/*.png
+var costumeSheetsFS embed.FS
+
+var costumeSheets = decodeSprites(costumeSheetsFS)
+
+// sprite is a costume sprite sheet along with its most common colors
+type sprite struct {
+	sheet  *image.RGBA
+	colors []color.RGBA
+}
+
+func decodeSprites(fs embed.FS) map[string]*sprite {
+	files, err := fs.ReadDir("costumes")
+	if err != nil {
+		panic("can't read the costume sprites: " + err.Error())
+	}
+
+	sprites := make(map[string]*sprite)
+	for _, f := range files {
+		b, err := fs.ReadFile("costumes/" + f.Name())
+		if err != nil {
+			panic("can't read the costume sprite: " + err.Error())
+		}
+		img, err := png.Decode(bytes.NewReader(b))
+		if err != nil {
+			panic("can't decode the costume sprite " + f.Name() + ": " + err.Error())
+		}
+		sheet := image.NewRGBA(img.Bounds())
+		imgdraw.Draw(sheet, sheet.Bounds(), img, img.Bounds().Min, imgdraw.Src)
+		sprites[strings.TrimSuffix(f.Name(), ".png")] = &sprite{sheet, commonColors(sheet, spritePaletteColors)}
+	}
+	return sprites
+}
+
+// commonColors returns up to n most common opaque colors of the image
+func commonColors(img *image.RGBA, n int) []color.RGBA {
+	counts := make(map[color.RGBA]int)
+	for i := 0; i+3 < len(img.Pix); i += 4 {
+		if img.Pix[i+3] == 0xff {
+			counts[color.RGBA{img.Pix[i], img.Pix[i+1], img.Pix[i+2], 0xff}]++
+		}
+	}
+
+	var colors []color.RGBA
+	for c := range counts {
+		colors = append(colors, c)
+	}
+	sort.Slice(colors, func(i, j int) bool {
+		ci, cj := counts[colors[i]], counts[colors[j]]
+		if ci != cj {
+			return ci > cj
+		}
+		return colorKey(colors[i]) < colorKey(colors[j])
+	})
+	if len(colors) > n {
+		colors = colors[:n]
+	}
+	return colors
+}
+
+func colorKey(c color.RGBA) uint32 {
+	return uint32(c.R)<<16 | uint32(c.G)<<8 | uint32(c.B)
+}
+
+// costumeSprite returns the sprite sheet of the costume, and the number
+// of frames and the pivot point (in sheet px) from the costume registry;
+// costumes without a sprite sheet are drawn as gophers
+func costumeSprite(name string) (s *sprite, frames int, pivotX, pivotY float64) {
+	s, ok := costumeSheets[name]
+	if !ok {
+		name = draw.DefaultCostume
+		s = costumeSheets[name]
+	}
+
+	h := float64(s.sheet.Bounds().Dy())
+	if c, ok := costumes[name]; ok {
+		scale := h / c.Height
+		return s, c.Frames, c.PivotX * scale, c.PivotY * scale
+	}
+
+	// square frames with the pivot in the center
+	frames = s.sheet.Bounds().Dx() / s.sheet.Bounds().Dy()
+	return s, frames, h / 2, h / 2
+}
+
+// timelapseOptions configures the time-lapse rendering
+type timelapseOptions struct {
+	Size int    // image width and height in px
+	FPS  int    // frames per second
+	Edge string // the board edge mode for artists that haven't set one
+}
+
+func (o timelapseOptions) validate() error {
+	if o.Size < minTimelapseSize || o.Size > maxTimelapseSize {
+		return fmt.Errorf("size should be between %d and %d", minTimelapseSize, maxTimelapseSize)
+	}
+	if o.FPS < 1 || o.FPS > maxTimelapseFPS {
+		return fmt.Errorf("fps should be between 1 and %d", maxTimelapseFPS)
+	}
+	switch o.Edge {
+	case "", draw.EdgeNone, draw.EdgeClamp, draw.EdgeWrap, draw.EdgeBounce:
+	default:
+		return fmt.Errorf("edge should be %s, %s, %s or %s", draw.EdgeNone, draw.EdgeClamp, draw.EdgeWrap, draw.EdgeBounce)
+	}
+	return nil
+}
+
+// maxFrames returns the number of frames the time-lapse can have
+func (o timelapseOptions) maxFrames() int {
+	n := maxTimelapsePixels / (o.Size * o.Size)
+	if n > maxTimelapseFrames {
+		n = maxTimelapseFrames
+	}
+	if n < 2 {
+		n = 2
+	}
+	return n
+}
+
+func timelapseOptionsFromQuery(q url.Values) (timelapseOptions, error) {
+	opts := timelapseOptions{
+		Size: defaultTimelapseSize,
+		FPS:  defaultTimelapseFPS,
+	}
+
+	if s := q.Get("size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return opts, fmt.Errorf("invalid size: %q", s)
+		}
+		opts.Size = n
+	}
+
+	if s := q.Get("fps"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return opts, fmt.Errorf("invalid fps: %q", s)
+		}
+		opts.FPS = n
+	}
+
+	opts.Edge = q.Get("edge")
+
+	return opts, opts.validate()
+}
+
+// TimelapseHandler renders an animated GIF of an artist's drawing
+// being made; `size`, `fps` and `edge` query parameters are optional
+func TimelapseHandler(w http.ResponseWriter, r *http.Request) {
+	artistID := mux.Vars(r)["artistID"]
+
+	// copy the moves, so that the artist can keep drawing
+	// while the time-lapse is rendered
+	mu.RLock()
+	_, ok := artists[artistID]
+	artistMoves := append([]Move(nil), history[artistID]...)
+	mu.RUnlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	opts, err := timelapseOptionsFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var actions []*draw.Action
+	for i, m := range artistMoves {
+		a, err := draw.ParseCommand(m.Description, i+1)
+		if err != nil {
+			continue
+		}
+		if m.Duration > 0 {
+			a.Duration = draw.Seconds(m.Duration)
+		}
+		actions = append(actions, a)
+	}
+
+	// rendering takes a lot of CPU and memory,
+	// so only a few time-lapses are rendered at once
+	timeout := time.NewTimer(timelapseQueueTimeout)
+	defer timeout.Stop()
+	select {
+	case timelapseSlots <- struct{}{}:
+		defer func() { <-timelapseSlots }()
+	case <-timeout.C:
+		http.Error(w, "too many time-lapses are being rendered, try again later", http.StatusServiceUnavailable)
+		return
+	case <-r.Context().Done():
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/gif")
+	if err := renderTimelapse(w, actions, opts); err != nil {
+		log.Printf("Error rendering the time-lapse for %s: %v", artistID, err)
+	}
+}
+
+// runTimelapseCommand renders a time-lapse of the artist's drawing
+// from a session file into a GIF file; artistID can be empty
+// if there's only one artist in the session
+func runTimelapseCommand(sessionPath, artistID, outPath string, opts timelapseOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	f, err := os.Open(sessionPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	events, err := draw.ReadSession(f)
+	if err != nil {
+		return err
+	}
+
+	if artistID == "" {
+		var ids []string
+		seen := make(map[string]bool)
+		for _, e := range events {
+			if !seen[e.Artist] {
+				seen[e.Artist] = true
+				ids = append(ids, e.Artist)
+			}
+		}
+		if len(ids) != 1 {
+			return fmt.Errorf("the session has %d artists (%s); choose one with -artist", len(ids), strings.Join(ids, ", "))
+		}
+		artistID = ids[0]
+	}
+
+	var actions []*draw.Action
+	line := 0
+	for _, e := range events {
+		if e.Artist != artistID || e.Type != draw.EventAction {
+			continue
+		}
+		line++
+		a, err := draw.ParseCommand(e.Cmd, line)
+		if err != nil {
+			log.Printf("Skipping: %v", err)
+			continue
+		}
+		if e.Duration > 0 {
+			a.Duration = draw.Seconds(float64(e.Duration) / 1000)
+		}
+		actions = append(actions, a)
+	}
+
+	if len(actions) == 0 {
+		return errors.New("no actions found for " + artistID)
+	}
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+
+	if err := renderTimelapse(out, actions, opts); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// timelapseMove is a single action along with the turtle state
+// before and after it, the segments it draws and its duration
+type timelapseMove struct {
+	from, to draw.Turtle
+	segments []draw.Segment
+	length   float64 // path length
+	duration time.Duration
+
+	// the gopher heading follows the path (for shapes)
+	// rather than turning evenly
+	followPath bool
+
+	costume string // the costume worn after the action
+}
+
+// planTimelapse runs the actions on a board with the given edge mode
+func planTimelapse(actions []*draw.Action, edge string) []timelapseMove {
+	var moves []timelapseMove
+
+	// the artist starts at the center of the board
+	t := draw.NewTurtle()
+	t.Board = draw.NewBoard(0, 0, edge)
+	costume := draw.DefaultCostume
+	for _, a := range actions {
+		from := *t
+		segments := t.Apply(a)
+		if a.Kind == draw.SetCostume {
+			costume = a.SVal
+		}
+
+		// the same timing as on the board
+		length := draw.PathLength(segments)
+		d := t.Duration(a, segments, t.Angle != from.Angle)
+
+		followPath := draw.FollowsPath(a, segments)
+		moves = append(moves, timelapseMove{from, *t, segments, length, d, followPath, costume})
+	}
+
+	return moves
+}
+
+// timelapseExtent returns the number of steps that should be visible
+// in each direction from the center to fit the whole drawing
+func timelapseExtent(moves []timelapseMove) float64 {
+	extent := float64(timelapseMinExtent)
+	fit := func(x, y float64) {
+		extent = math.Max(extent, math.Ceil(math.Max(math.Abs(x), math.Abs(y)))+1)
+	}
+	for _, m := range moves {
+		fit(m.to.X, m.to.Y)
+		for _, s := range m.segments {
+			fit(s.X1, s.Y1)
+			fit(s.X2, s.Y2)
+			if s.Arc {
+				fit(s.CX-s.R, s.CY-s.R)
+				fit(s.CX+s.R, s.CY+s.R)
+			}
+		}
+	}
+	return extent
+}
+
+// timelapseRenderer draws the board in pixels
+type timelapseRenderer struct {
+	size     float64
+	stepSize float64
+	extent   float64
+	colors   map[string]color.RGBA
+}
+
+func (r *timelapseRenderer) toScreen(x, y float64) (float64, float64) {
+	return r.size/2 + x*r.stepSize, r.size/2 + y*r.stepSize
+}
+
+func (r *timelapseRenderer) color(s string) (color.RGBA, bool) {
+	c, ok := r.colors[s]
+	if !ok {
+		var err error
+		c, err = draw.ParseColor(s)
+		ok = err == nil
+		if ok {
+			r.colors[s] = c
+		}
+	}
+	return c, ok
+}
+
+func (r *timelapseRenderer) renderGrid(img *image.RGBA) {
+	imgdraw.Draw(img, img.Bounds(), image.NewUniform(timelapseBgColor), image.Point{}, imgdraw.Src)
+
+	step := 1
+	for r.extent*2/float64(step) > maxTimelapseGridLines {
+		step *= 5
+	}
+
+	n := int(r.extent)/step + 1
+	for k := -n; k <= n; k++ {
+		i := k * step
+		c := timelapseBoardColor
+		if i%(5*step) == 0 {
+			c = timelapseFifthColor
+		}
+		if i == 0 {
+			c = timelapseCenterColor
+		}
+		p, _ := r.toScreen(float64(i), 0)
+		strokeLine(img, p, 0, p, r.size, 1, c)
+		strokeLine(img, 0, p, r.size, p, 1, c)
+	}
+}
+
+// points returns the screen points along the segment,
+// approximating arcs with lines about 3px long
+func (r *timelapseRenderer) points(s draw.Segment) []point {
+	x, y := r.toScreen(s.X1, s.Y1)
+	pts := []point{{x, y}}
+	if !s.Arc {
+		x, y = r.toScreen(s.X2, s.Y2)
+		return append(pts, point{x, y})
+	}
+
+	n := int(math.Ceil(s.Length() * r.stepSize / 3))
+	if n < 1 {
+		n = 1
+	}
+	for i := 1; i <= n; i++ {
+		x, y, _ := s.At(s.Length() * float64(i) / float64(n))
+		x, y = r.toScreen(x, y)
+		pts = append(pts, point{x, y})
+	}
+	return pts
+}
+
+func (r *timelapseRenderer) renderSegments(img *image.RGBA, segments []draw.Segment) {
+	for _, s := range segments {
+		c, ok := r.color(s.Color)
+		if !ok {
+			continue
+		}
+
+		if s.Outline != nil {
+			var pts []point
+			for _, o := range s.Outline {
+				pts = append(pts, r.points(o)...)
+			}
+			fillPolygon(img, pts, s.FillRule == draw.EvenOdd, withOpacity(c, s.Opacity))
+			r.renderSegments(img, s.Outline)
+			continue
+		}
+
+		// gradients go from c to c2 along the g1 - g2 line
+		c2, gradient := r.color(s.GradientTo)
+		gx1, gy1 := r.toScreen(s.GX1, s.GY1)
+		gx2, gy2 := r.toScreen(s.GX2, s.GY2)
+		gdx, gdy := gx2-gx1, gy2-gy1
+
+		// dots are zero-length lines with round caps;
+		// line caps are always round in time-lapses
+		for _, p := range dashPieces(r.points(s), s.Dash, s.Offset*r.stepSize) {
+			pc := c
+			if l2 := gdx*gdx + gdy*gdy; gradient && l2 > 0 {
+				x, y := (p[0].x+p[1].x)/2, (p[0].y+p[1].y)/2
+				k := math.Max(0, math.Min(1, ((x-gx1)*gdx+(y-gy1)*gdy)/l2))
+				pc = mixColors(c, c2, k)
+			}
+			strokeLine(img, p[0].x, p[0].y, p[1].x, p[1].y, s.Width, withOpacity(pc, s.Opacity))
+		}
+	}
+}
+
+// dashPieces splits the polyline into the pieces drawn with the dash
+// pattern (in px) started at the offset (in px); without a pattern,
+// all the polyline pieces are returned
+func dashPieces(pts []point, dash []float64, offset float64) [][2]point {
+	period := 0.0
+	for _, d := range dash {
+		if d <= 0 {
+			dash = nil // zero gaps make solid lines
+			break
+		}
+		period += d
+	}
+
+	var out [][2]point
+	pos := offset
+	for i := 1; i < len(pts); i++ {
+		p1, p2 := pts[i-1], pts[i]
+		if dash == nil {
+			out = append(out, [2]point{p1, p2})
+			continue
+		}
+
+		l := math.Hypot(p2.x-p1.x, p2.y-p1.y)
+		at := func(d float64) point {
+			return point{p1.x + (p2.x-p1.x)*d/l, p1.y + (p2.y-p1.y)*d/l}
+		}
+		for d := 0.0; d < l; {
+			// find the dash (even index) or the gap (odd index) at pos
+			k := math.Mod(pos, period)
+			j := 0
+			for j < len(dash)-1 && k >= dash[j] {
+				k -= dash[j]
+				j++
+			}
+			n := math.Min(math.Max(dash[j]-k, 1e-6), l-d)
+			if j%2 == 0 {
+				out = append(out, [2]point{at(d), at(d + n)})
+			}
+			d += n
+			pos += n
+		}
+	}
+	return out
+}
+
+// mixColors returns the color k of the way from c1 to c2
+func mixColors(c1, c2 color.RGBA, k float64) color.RGBA {
+	mix := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a) + (float64(b)-float64(a))*k))
+	}
+	return color.RGBA{mix(c1.R, c2.R), mix(c1.G, c2.G), mix(c1.B, c2.B), mix(c1.A, c2.A)}
+}
+
+// withOpacity returns the (premultiplied) color with the given opacity
+func withOpacity(c color.RGBA, opacity float64) color.RGBA {
+	if opacity >= 1 {
+		return c
+	}
+	return mixColors(color.RGBA{}, c, opacity)
+}
+
+// blendRGBA paints the (premultiplied) color over the pixel
+func blendRGBA(img *image.RGBA, x, y int, c color.RGBA) {
+	if c.A == 255 {
+		img.SetRGBA(x, y, c)
+		return
+	}
+	dst := img.RGBAAt(x, y)
+	k := float64(255-c.A) / 255
+	blend := func(s, d uint8) uint8 {
+		return s + uint8(math.Round(float64(d)*k))
+	}
+	img.SetRGBA(x, y, color.RGBA{blend(c.R, dst.R), blend(c.G, dst.G), blend(c.B, dst.B), blend(c.A, dst.A)})
+}
+
+func (r *timelapseRenderer) renderCostume(img *image.RGBA, costume string, x, y, angle float64) {
+	cx, cy := r.toScreen(x, y)
+	size := math.Max(2*r.stepSize, 16) // the character is about 2 steps tall on the board
+	drawSprite(img, costume, cx, cy, angle, size)
+}
+
+// palette returns the GIF palette with grid, costume and drawing colors first
+func (r *timelapseRenderer) palette(costumes []string) color.Palette {
+	p := color.Palette{
+		timelapseBgColor, timelapseBoardColor, timelapseFifthColor, timelapseCenterColor,
+	}
+
+	seen := make(map[color.Color]bool)
+	for _, c := range p {
+		seen[c] = true
+	}
+
+	for _, name := range costumes {
+		s, _, _, _ := costumeSprite(name)
+		for _, c := range s.colors {
+			if !seen[c] {
+				seen[c] = true
+				p = append(p, c)
+			}
+		}
+	}
+
+	for _, c := range r.colors {
+		if !seen[c] && len(p) < 128 {
+			seen[c] = true
+			p = append(p, c)
+		}
+	}
+
+	for _, c := range palette.WebSafe {
+		if len(p) == 256 {
+			break
+		}
+		if !seen[c] {
+			p = append(p, c)
+		}
+	}
+
+	return p
+}
+
+// renderTimelapse writes an animated GIF of the actions being executed;
+// the frames are written as they are rendered
+func renderTimelapse(w io.Writer, actions []*draw.Action, opts timelapseOptions) error {
+	edge := opts.Edge
+	if edge == "" {
+		edge = draw.EdgeNone
+	}
+	moves := planTimelapse(actions, edge)
+
+	r := &timelapseRenderer{
+		size:   float64(opts.Size),
+		extent: timelapseExtent(moves),
+		colors: make(map[string]color.RGBA),
+	}
+	r.stepSize = r.size / (r.extent*2 + 1) // "+1" to add 0.5 steps around
+
+	// resolve all the colors upfront to build the palette
+	costumes := []string{draw.DefaultCostume}
+	for _, m := range moves {
+		if m.costume != costumes[len(costumes)-1] {
+			costumes = append(costumes, m.costume)
+		}
+		for _, s := range m.segments {
+			r.color(s.Color)
+			r.color(s.GradientTo)
+			for _, o := range s.Outline {
+				r.color(o.Color)
+				r.color(o.GradientTo)
+			}
+		}
+	}
+	pal := r.palette(costumes)
+
+	var total time.Duration
+	for _, m := range moves {
+		total += m.duration
+	}
+
+	// there is a frame at the start and one after each frameDuration
+	// until all the moves are complete
+	frameDuration := time.Second / time.Duration(opts.FPS)
+	if n := time.Duration(opts.maxFrames() - 1); total > frameDuration*n {
+		frameDuration = (total + n - 1) / n
+	}
+	delay := int(frameDuration / (10 * time.Millisecond))
+	if delay < 1 {
+		delay = 1
+	}
+	if delay > math.MaxUint16 {
+		delay = math.MaxUint16
+	}
+
+	bounds := image.Rect(0, 0, opts.Size, opts.Size)
+	base := image.NewRGBA(bounds) // grid and all the completed moves
+	r.renderGrid(base)
+
+	frame := image.NewRGBA(bounds)
+	gw, err := newGIFWriter(w, bounds, pal)
+	if err != nil {
+		return err
+	}
+
+	i := 0                  // current move
+	var start time.Duration // start of the current move
+	for t := time.Duration(0); ; t += frameDuration {
+		// complete all the moves that end by the moment t
+		for i < len(moves) && start+moves[i].duration <= t {
+			r.renderSegments(base, moves[i].segments)
+			start += moves[i].duration
+			i++
+		}
+
+		copy(frame.Pix, base.Pix)
+
+		turtle := draw.NewTurtle()
+		costume := draw.DefaultCostume
+		if i > 0 {
+			turtle = &moves[i-1].to
+			costume = moves[i-1].costume
+		}
+		if i < len(moves) {
+			m := moves[i]
+			rel := float64(t-start) / float64(m.duration)
+			r.renderSegments(frame, draw.SlicePath(m.segments, 0, m.length*rel))
+			turtle = &draw.Turtle{
+				X:     m.from.X + (m.to.X-m.from.X)*rel,
+				Y:     m.from.Y + (m.to.Y-m.from.Y)*rel,
+				Angle: m.from.Angle + (m.to.Angle-m.from.Angle)*rel,
+			}
+			if m.length > 0 {
+				var heading float64
+				turtle.X, turtle.Y, heading = draw.PathAt(m.segments, m.length*rel)
+				if m.followPath {
+					turtle.Angle = heading
+				}
+			}
+		}
+		r.renderCostume(frame, costume, turtle.X, turtle.Y, turtle.Angle)
+
+		if i == len(moves) {
+			if err := gw.writeFrame(frame, timelapseLastFrameDelay); err != nil {
+				return err
+			}
+			break
+		}
+		if err := gw.writeFrame(frame, delay); err != nil {
+			return err
+		}
+	}
+
+	return gw.close()
+}
+
+type point struct {
+	x, y float64
+}
+
+// fillPolygon fills the closed polygon using the nonzero winding rule,
+// or the even-odd rule if evenOdd is true
+func fillPolygon(img *image.RGBA, pts []point, evenOdd bool, c color.RGBA) {
+	if len(pts) < 3 {
+		return
+	}
+
+	minY, maxY := pts[0].y, pts[0].y
+	for _, p := range pts {
+		minY, maxY = math.Min(minY, p.y), math.Max(maxY, p.y)
+	}
+	b := img.Bounds()
+	y1 := int(math.Max(math.Floor(minY), float64(b.Min.Y)))
+	y2 := int(math.Min(math.Ceil(maxY), float64(b.Max.Y)))
+
+	type crossing struct {
+		x   float64
+		dir int
+	}
+	var xs []crossing
+
+	for py := y1; py < y2; py++ {
+		// find where the edges cross the scanline at the pixel centers
+		y := float64(py) + 0.5
+		xs = xs[:0]
+		for i := range pts {
+			p1, p2 := pts[i], pts[(i+1)%len(pts)]
+			dir := 1
+			if p1.y > p2.y {
+				p1, p2 = p2, p1
+				dir = -1
+			}
+			if y < p1.y || y >= p2.y {
+				continue
+			}
+			xs = append(xs, crossing{p1.x + (y-p1.y)*(p2.x-p1.x)/(p2.y-p1.y), dir})
+		}
+		sort.Slice(xs, func(i, j int) bool { return xs[i].x < xs[j].x })
+
+		winding := 0
+		for i := 0; i < len(xs)-1; i++ {
+			if evenOdd {
+				winding++
+			} else {
+				winding += xs[i].dir
+			}
+			inside := winding != 0
+			if evenOdd {
+				inside = winding%2 == 1
+			}
+			if !inside {
+				continue
+			}
+			from := int(math.Max(math.Ceil(xs[i].x-0.5), float64(b.Min.X)))
+			to := int(math.Min(math.Ceil(xs[i+1].x-0.5), float64(b.Max.X)))
+			for px := from; px < to; px++ {
+				blendRGBA(img, px, py, c)
+			}
+		}
+	}
+}
+
+// strokeLine draws a line of the given width with round caps
+func strokeLine(img *image.RGBA, x1, y1, x2, y2, width float64, c color.RGBA) {
+	r := math.Max(width, 1) / 2
+	b := image.Rect(
+		int(math.Floor(math.Min(x1, x2)-r)), int(math.Floor(math.Min(y1, y2)-r)),
+		int(math.Ceil(math.Max(x1, x2)+r))+1, int(math.Ceil(math.Max(y1, y2)+r))+1,
+	).Intersect(img.Bounds())
+
+	dx, dy := x2-x1, y2-y1
+	l2 := dx*dx + dy*dy
+
+	for py := b.Min.Y; py < b.Max.Y; py++ {
+		for px := b.Min.X; px < b.Max.X; px++ {
+			// distance from the pixel center to the segment
+			x, y := float64(px)+0.5, float64(py)+0.5
+			k := 0.0
+			if l2 > 0 {
+				k = math.Max(0, math.Min(1, ((x-x1)*dx+(y-y1)*dy)/l2))
+			}
+			if math.Hypot(x-(x1+dx*k), y-(y1+dy*k)) <= r {
+				blendRGBA(img, px, py, c)
+			}
+		}
+	}
+}
+
+// drawSprite draws the costume size px tall with its pivot at cx, cy
+// and rotated clockwise by angle degrees (0 means facing up)
+func drawSprite(img *image.RGBA, costume string, cx, cy, angle, size float64) {
+	rad := angle * math.Pi / 180
+	sin, cos := math.Sin(rad), math.Cos(rad)
+
+	// the standing frame, the same as the board shows when turning
+	s, frames, pivotX, pivotY := costumeSprite(costume)
+	fw := float64(s.sheet.Bounds().Dx() / frames)
+	fh := float64(s.sheet.Bounds().Dy())
+	fx := fw * float64((frames-1)/2)
+	scale := fh / size // sprite px per image px
+
+	// no part of the frame is further from the pivot than its diagonal
+	reach := math.Hypot(fw, fh) / scale
+	b := image.Rect(
+		int(cx-reach), int(cy-reach), int(cx+reach)+1, int(cy+reach)+1,
+	).Intersect(img.Bounds())
+
+	for py := b.Min.Y; py < b.Max.Y; py++ {
+		for px := b.Min.X; px < b.Max.X; px++ {
+			// rotate the pixel center back into the frame coordinates
+			dx, dy := float64(px)+0.5-cx, float64(py)+0.5-cy
+			u := (dx*cos+dy*sin)*scale + pivotX
+			v := (-dx*sin+dy*cos)*scale + pivotY
+			if u < 0 || v < 0 || u >= fw || v >= fh {
+				continue
+			}
+			if c := sampleSprite(s.sheet, fx+u, v); c.A > 0 {
+				blendRGBA(img, px, py, c)
+			}
+		}
+	}
+}
+
+// sampleSprite returns the (premultiplied) color of the sprite
+// at the x, y point, interpolated between the nearest pixels
+func sampleSprite(sprite *image.RGBA, x, y float64) color.RGBA {
+	x, y = x-0.5, y-0.5
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+
+	var r, g, b, a float64
+	add := func(px, py, k float64) {
+		c := sprite.RGBAAt(int(px), int(py))
+		r += float64(c.R) * k
+		g += float64(c.G) * k
+		b += float64(c.B) * k
+		a += float64(c.A) * k
+	}
+	add(x0, y0, (1-fx)*(1-fy))
+	add(x0+1, y0, fx*(1-fy))
+	add(x0, y0+1, (1-fx)*fy)
+	add(x0+1, y0+1, fx*fy)
+
+	round := func(v float64) uint8 {
+		return uint8(math.Min(255, math.Round(v)))
+	}
+	return color.RGBA{round(r), round(g), round(b), round(a)}
+}
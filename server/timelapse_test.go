@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"math"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/iafan/goplayspace/client/draw"
+)
+
+func TestTimelapseOptionsFromQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  timelapseOptions
+		err   string
+	}{
+		{"", timelapseOptions{Size: defaultTimelapseSize, FPS: defaultTimelapseFPS}, ""},
+		{"size=64&fps=1", timelapseOptions{Size: 64, FPS: 1}, ""},
+		{"size=1024&fps=50", timelapseOptions{Size: 1024, FPS: 50}, ""},
+		{"edge=bounce", timelapseOptions{Size: defaultTimelapseSize, FPS: defaultTimelapseFPS, Edge: draw.EdgeBounce}, ""},
+		{"size=big", timelapseOptions{}, `invalid size: "big"`},
+		{"fps=1.5", timelapseOptions{}, `invalid fps: "1.5"`},
+		{"size=63", timelapseOptions{}, "size should be between 64 and 1024"},
+		{"size=100000", timelapseOptions{}, "size should be between 64 and 1024"},
+		{"fps=0", timelapseOptions{}, "fps should be between 1 and 50"},
+		{"fps=51", timelapseOptions{}, "fps should be between 1 and 50"},
+		{"edge=bend", timelapseOptions{}, "edge should be none, clamp, wrap or bounce"},
+	}
+
+	for _, tt := range tests {
+		q, _ := url.ParseQuery(tt.query)
+		opts, err := timelapseOptionsFromQuery(q)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("timelapseOptionsFromQuery(%q) error = %v, want %q", tt.query, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("timelapseOptionsFromQuery(%q): %v", tt.query, err)
+			continue
+		}
+		if opts != tt.want {
+			t.Errorf("timelapseOptionsFromQuery(%q) = %+v, want %+v", tt.query, opts, tt.want)
+		}
+	}
+}
+
+func TestDashPieces(t *testing.T) {
+	line := []point{{0, 0}, {10, 0}}
+	corner := []point{{0, 0}, {3, 0}, {3, 3}}
+
+	tests := []struct {
+		pts    []point
+		dash   []float64
+		offset float64
+		want   [][2]point
+	}{
+		{line, nil, 0, [][2]point{{{0, 0}, {10, 0}}}},
+		{line, []float64{4, 0}, 0, [][2]point{{{0, 0}, {10, 0}}}},
+		{line, []float64{4, 2}, 0, [][2]point{{{0, 0}, {4, 0}}, {{6, 0}, {10, 0}}}},
+		{line, []float64{4, 2}, 5, [][2]point{{{1, 0}, {5, 0}}, {{7, 0}, {10, 0}}}},
+		{line, []float64{3, 3}, 3, [][2]point{{{3, 0}, {6, 0}}, {{9, 0}, {10, 0}}}},
+
+		// the dash pattern continues around the corners
+		{corner, []float64{2, 2}, 0, [][2]point{{{0, 0}, {2, 0}}, {{3, 1}, {3, 3}}}},
+	}
+
+	for _, tt := range tests {
+		got := dashPieces(tt.pts, tt.dash, tt.offset)
+		if len(got) != len(tt.want) {
+			t.Errorf("dashPieces(%v, %v, %v) = %v, want %v", tt.pts, tt.dash, tt.offset, got, tt.want)
+			continue
+		}
+		for i := range got {
+			for j := range got[i] {
+				if math.Abs(got[i][j].x-tt.want[i][j].x) > 1e-6 || math.Abs(got[i][j].y-tt.want[i][j].y) > 1e-6 {
+					t.Errorf("dashPieces(%v, %v, %v) = %v, want %v", tt.pts, tt.dash, tt.offset, got, tt.want)
+				}
+			}
+		}
+	}
+}
+
+func TestFillPolygon(t *testing.T) {
+	// two squares traced in the same direction, one inside the other
+	outer := []point{{0, 0}, {10, 0}, {10, 10}, {0, 10}}
+	inner := []point{{3, 3}, {7, 3}, {7, 7}, {3, 7}}
+	nested := append(append(append([]point{}, outer...), outer[0]), inner...)
+	nested = append(nested, inner[0])
+
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	tests := []struct {
+		pts          []point
+		evenOdd      bool
+		center, side bool // whether (5, 5) and (1, 5) are filled
+	}{
+		{outer, false, true, true},
+		{outer, true, true, true},
+		{nested, false, true, true},
+		{nested, true, false, true},
+		{outer[:2], false, false, false},
+	}
+
+	for _, tt := range tests {
+		img := image.NewRGBA(image.Rect(0, 0, 12, 12))
+		fillPolygon(img, tt.pts, tt.evenOdd, red)
+
+		if filled := img.RGBAAt(5, 5) == red; filled != tt.center {
+			t.Errorf("fillPolygon(%v, evenOdd: %v): center filled = %v, want %v", tt.pts, tt.evenOdd, filled, tt.center)
+		}
+		if filled := img.RGBAAt(1, 5) == red; filled != tt.side {
+			t.Errorf("fillPolygon(%v, evenOdd: %v): side filled = %v, want %v", tt.pts, tt.evenOdd, filled, tt.side)
+		}
+		if img.RGBAAt(11, 11) == red {
+			t.Errorf("fillPolygon(%v, evenOdd: %v) has filled outside of the polygon", tt.pts, tt.evenOdd)
+		}
+	}
+}
+
+func parseActions(t *testing.T, cmds ...string) []*draw.Action {
+	t.Helper()
+	var actions []*draw.Action
+	for i, cmd := range cmds {
+		a, err := draw.ParseCommand(cmd, i+1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actions = append(actions, a)
+	}
+	return actions
+}
+
+func TestTimelapseExtent(t *testing.T) {
+	tests := []struct {
+		cmds []string
+		want float64
+	}{
+		{nil, timelapseMinExtent},
+		{[]string{"forward 3"}, timelapseMinExtent},
+		{[]string{"forward 7.5"}, 9},
+		{[]string{"goto -20 3", "home"}, 21},
+		{[]string{"circle 10"}, 21},
+		{[]string{"goto 1000 -1000", "circle 1000"}, 3001},
+	}
+
+	for _, tt := range tests {
+		moves := planTimelapse(parseActions(t, tt.cmds...), draw.EdgeNone)
+		if got := timelapseExtent(moves); got != tt.want {
+			t.Errorf("timelapseExtent(%q) = %v, want %v", tt.cmds, got, tt.want)
+		}
+	}
+}
+
+func TestTimelapseMaxFrames(t *testing.T) {
+	tests := []struct {
+		size int
+		want int
+	}{
+		{minTimelapseSize, maxTimelapseFrames},
+		{maxTimelapseSize, maxTimelapsePixels / (maxTimelapseSize * maxTimelapseSize)},
+	}
+
+	for _, tt := range tests {
+		if got := (timelapseOptions{Size: tt.size}).maxFrames(); got != tt.want {
+			t.Errorf("maxFrames() at size %d = %d, want %d", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestPlanTimelapse(t *testing.T) {
+	moves := planTimelapse(parseActions(t, "forward", "costume turtle", "forward", "costume nosuchcostume"), draw.EdgeNone)
+	for i, want := range []string{draw.DefaultCostume, "turtle", "turtle", "nosuchcostume"} {
+		if moves[i].costume != want {
+			t.Errorf("move %d costume = %q, want %q", i, moves[i].costume, want)
+		}
+	}
+
+	// the edge mode applies to artists that haven't set one
+	tests := []struct {
+		edge string
+		cmds []string
+		want float64
+	}{
+		{draw.EdgeNone, []string{"forward 20"}, -20},
+		{draw.EdgeClamp, []string{"forward 20"}, -draw.BoardExtent},
+		{draw.EdgeWrap, []string{"forward 20"}, 10},
+		{draw.EdgeBounce, []string{"forward 20"}, -10},
+		{draw.EdgeBounce, []string{"edge none", "forward 20"}, -20},
+	}
+
+	for _, tt := range tests {
+		moves := planTimelapse(parseActions(t, tt.cmds...), tt.edge)
+		if y := moves[len(moves)-1].to.Y; math.Abs(y-tt.want) > 1e-9 {
+			t.Errorf("planTimelapse(%q, %q) ends at y = %v, want %v", tt.cmds, tt.edge, y, tt.want)
+		}
+	}
+}
+
+func TestCostumeSprite(t *testing.T) {
+	for name := range costumeSheets {
+		s, frames, pivotX, pivotY := costumeSprite(name)
+		if frames < 1 || len(s.colors) == 0 {
+			t.Errorf("costumeSprite(%q) has %d frames and %d colors", name, frames, len(s.colors))
+		}
+		h := float64(s.sheet.Bounds().Dy())
+		if pivotX <= 0 || pivotY <= 0 || pivotX >= h || pivotY >= h {
+			t.Errorf("costumeSprite(%q) pivot is %v, %v", name, pivotX, pivotY)
+		}
+	}
+
+	// costumes without a sprite sheet are drawn as gophers
+	if s, _, _, _ := costumeSprite("nosuchcostume"); s != costumeSheets[draw.DefaultCostume] {
+		t.Errorf("costumeSprite(%q) isn't the gopher", "nosuchcostume")
+	}
+}
+
+func TestRenderTimelapse(t *testing.T) {
+	tests := []struct {
+		cmds      []string
+		opts      timelapseOptions
+		maxFrames int
+	}{
+		{nil, timelapseOptions{Size: minTimelapseSize, FPS: maxTimelapseFPS}, 1},
+		{[]string{"pendown", "forward 2", "right", "color red", "circle 1"}, timelapseOptions{Size: minTimelapseSize, FPS: maxTimelapseFPS}, 250},
+		{[]string{"costume turtle", "pendown", "forward 20"}, timelapseOptions{Size: minTimelapseSize, FPS: maxTimelapseFPS, Edge: draw.EdgeWrap}, 501},
+
+		// huge drawings are scaled down, and long ones are sped up
+		{[]string{"pendown", "goto 1000 -1000", "circle 1000", "polygon 360 1000"}, timelapseOptions{Size: minTimelapseSize, FPS: maxTimelapseFPS}, maxTimelapseFrames},
+		{[]string{"wait 60", "wait 60", "wait 60", "wait 60", "wait 60"}, timelapseOptions{Size: minTimelapseSize, FPS: maxTimelapseFPS}, maxTimelapseFrames},
+
+		// big ones get fewer frames
+		{[]string{"pendown", "wait 60", "forward 5"}, timelapseOptions{Size: maxTimelapseSize, FPS: maxTimelapseFPS}, maxTimelapsePixels / (maxTimelapseSize * maxTimelapseSize)},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		start := time.Now()
+		err := renderTimelapse(&buf, parseActions(t, tt.cmds...), tt.opts)
+		if err != nil {
+			t.Errorf("renderTimelapse(%q): %v", tt.cmds, err)
+			continue
+		}
+		if d := time.Since(start); d > 10*time.Second {
+			t.Errorf("renderTimelapse(%q) took %v", tt.cmds, d)
+		}
+
+		anim, err := gif.DecodeAll(&buf)
+		if err != nil {
+			t.Errorf("renderTimelapse(%q) has rendered an invalid GIF: %v", tt.cmds, err)
+			continue
+		}
+		if n := len(anim.Image); n < 1 || n > tt.maxFrames {
+			t.Errorf("renderTimelapse(%q) has rendered %d frames, want 1 to %d", tt.cmds, n, tt.maxFrames)
+		}
+		if last := anim.Delay[len(anim.Delay)-1]; last != timelapseLastFrameDelay {
+			t.Errorf("renderTimelapse(%q) shows the last frame for %d, want %d", tt.cmds, last, timelapseLastFrameDelay)
+		}
+	}
+}